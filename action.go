@@ -0,0 +1,148 @@
+package fresher
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/goccy/go-yaml"
+)
+
+const (
+	// ActionRebuild runs the whole pipeline of BuildConfig. it is the default action of WatcherConfig.
+	ActionRebuild = "rebuild"
+	// ActionRestart restarts the running binary without building it again.
+	ActionRestart = "restart"
+	// ActionRunHook runs the after commands of BuildConfig for the running binary.
+	ActionRunHook = "run-hook"
+	// ActionIgnore does nothing for the changed files.
+	ActionIgnore = "ignore"
+)
+
+func isBuiltinAction(name string) bool {
+	switch name {
+	case ActionRebuild, ActionRestart, ActionRunHook, ActionIgnore:
+		return true
+	}
+	return false
+}
+
+// ActionRule decides the action for the watched files matching the pattern instead of the action of WatcherConfig.
+type ActionRule struct {
+	Pattern string `yaml:"pattern"`
+	Action  string `yaml:"action"`
+}
+
+// Action is a named set of commands run when the watched files in WatcherConfig referring to it are changed.
+// Then decides what to do after the commands succeed, one of ActionRebuild, ActionRestart, ActionRunHook or empty to do nothing.
+type Action struct {
+	Name     string     `yaml:"-"`
+	Commands []*Command `yaml:"run"`
+	Then     string     `yaml:"then"`
+}
+
+func (a *Action) UnmarshalYAML(b []byte) error {
+	st := struct {
+		Commands []*Command `yaml:"run"`
+		Then     string     `yaml:"then"`
+	}{}
+	if err := yaml.Unmarshal(b, &st); err != nil {
+		return err
+	}
+	switch st.Then {
+	case "", ActionRebuild, ActionRestart, ActionRunHook:
+	default:
+		return fmt.Errorf("unsupported action to run after commands: %s", st.Then)
+	}
+	a.Commands = st.Commands
+	a.Then = st.Then
+	return nil
+}
+
+type Actions map[string]*Action
+
+func (a *Actions) UnmarshalYAML(b []byte) error {
+	m := map[string]*Action{}
+	if err := yaml.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	for name, action := range m {
+		if isBuiltinAction(name) {
+			return fmt.Errorf("action name %s is reserved", name)
+		}
+		action.Name = name
+	}
+	*a = m
+	return nil
+}
+
+func (a Actions) Validate(configs []*WatcherConfig) error {
+	for _, wc := range configs {
+		if err := a.validate(wc.action()); err != nil {
+			return fmt.Errorf("%v in path %s", err, wc.Name)
+		}
+		for _, rule := range wc.Rules {
+			if err := validatePattern(rule.Pattern); err != nil {
+				return fmt.Errorf("invalid pattern %s in path %s: %v", rule.Pattern, wc.Name, err)
+			}
+			if err := a.validate(rule.Action); err != nil {
+				return fmt.Errorf("%v for %s in path %s", err, rule.Pattern, wc.Name)
+			}
+		}
+	}
+	return nil
+}
+
+func (a Actions) validate(name string) error {
+	if isBuiltinAction(name) {
+		return nil
+	}
+	if _, exists := a[name]; !exists {
+		return fmt.Errorf("undefined action %s", name)
+	}
+	return nil
+}
+
+// actionPlan is what to do for the changed files collected while waiting for the interval.
+type actionPlan struct {
+	actions []*Action
+	rebuild bool
+	restart bool
+	runHook bool
+}
+
+func (a Actions) plan(names map[string]struct{}) *actionPlan {
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	plan := &actionPlan{}
+	for _, name := range sorted {
+		switch name {
+		case ActionRebuild:
+			plan.rebuild = true
+			continue
+		case ActionRestart:
+			plan.restart = true
+			continue
+		case ActionRunHook:
+			plan.runHook = true
+			continue
+		}
+		action, exists := a[name]
+		if !exists {
+			continue
+		}
+		plan.actions = append(plan.actions, action)
+		switch action.Then {
+		case ActionRebuild:
+			plan.rebuild = true
+		case ActionRestart:
+			plan.restart = true
+		case ActionRunHook:
+			plan.runHook = true
+		}
+	}
+	return plan
+}
@@ -0,0 +1,101 @@
+package fresher
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Diagnostic is a compile error reported by go build.
+type Diagnostic struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (d *Diagnostic) Position() string {
+	if d.Column > 0 {
+		return fmt.Sprintf("%s:%d:%d", d.File, d.Line, d.Column)
+	}
+	return fmt.Sprintf("%s:%d", d.File, d.Line)
+}
+
+func (d *Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s", d.Position(), d.Message)
+}
+
+// BuildError is returned when go build fails. Diagnostics is empty if the output could not be parsed, e.g. link errors.
+type BuildError struct {
+	*ExitError
+	Diagnostics []*Diagnostic
+}
+
+func (e *BuildError) Error() string {
+	if len(e.Diagnostics) == 0 {
+		return fmt.Sprintf("failed to build: %s", e.ExitError.Error())
+	}
+	return fmt.Sprintf("failed to build with %d errors: %s", len(e.Diagnostics), e.ExitError.Error())
+}
+
+func NewBuildError(err *ExitError) *BuildError {
+	return &BuildError{
+		ExitError:   err,
+		Diagnostics: ParseDiagnostics(err.Stderr),
+	}
+}
+
+var diagnosticPattern = regexp.MustCompile(`^(.+\.go):(\d+)(?::(\d+))?: (.+)$`)
+
+// ParseDiagnostics parses the output of go build. the indented lines following a diagnostic are joined to its message.
+func ParseDiagnostics(output []byte) []*Diagnostic {
+	var diagnostics []*Diagnostic
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "\t") && len(diagnostics) > 0 {
+			last := diagnostics[len(diagnostics)-1]
+			last.Message = fmt.Sprintf("%s\n%s", last.Message, line)
+			continue
+		}
+		matches := diagnosticPattern.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		lineNum, _ := strconv.Atoi(matches[2])
+		column, _ := strconv.Atoi(matches[3])
+		diagnostics = append(diagnostics, &Diagnostic{
+			File:    matches[1],
+			Line:    lineNum,
+			Column:  column,
+			Message: matches[4],
+		})
+	}
+	return diagnostics
+}
+
+// BuildCommand runs go build and converts its failure into BuildError.
+type BuildCommand struct {
+	*Command
+}
+
+func (c *BuildCommand) ExecContext(ctx context.Context) error {
+	if err := c.Command.ExecContext(ctx); err != nil {
+		if exitErr, ok := err.(*ExitError); ok {
+			return NewBuildError(exitErr)
+		}
+		return err
+	}
+	return nil
+}
+
+func (c *BuildCommand) Exec() error {
+	if err := c.ExecContext(context.Background()); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package fresher
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDiagnostics(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []*Diagnostic
+	}{
+		{
+			name:   "file:line:col",
+			output: "./main.go:9:92: syntax error: unexpected )\n",
+			want: []*Diagnostic{
+				{File: "./main.go", Line: 9, Column: 92, Message: "syntax error: unexpected )"},
+			},
+		},
+		{
+			name:   "file:line",
+			output: "lib/lib.go:3: undefined: x\n",
+			want: []*Diagnostic{
+				{File: "lib/lib.go", Line: 3, Message: "undefined: x"},
+			},
+		},
+		{
+			name: "package headers",
+			output: "# example.com/app/lib\n" +
+				"lib/lib.go:3:2: undefined: x\n" +
+				"# example.com/app\n" +
+				"./main.go:5:1: missing return\n",
+			want: []*Diagnostic{
+				{File: "lib/lib.go", Line: 3, Column: 2, Message: "undefined: x"},
+				{File: "./main.go", Line: 5, Column: 1, Message: "missing return"},
+			},
+		},
+		{
+			name: "continuation lines",
+			output: "# example.com/app\n" +
+				"./main.go:7:6: cannot use s (variable of type string) as int value in assignment:\n" +
+				"\thave string\n" +
+				"\twant int\n" +
+				"./main.go:8:2: x declared and not used\n",
+			want: []*Diagnostic{
+				{File: "./main.go", Line: 7, Column: 6, Message: "cannot use s (variable of type string) as int value in assignment:\n\thave string\n\twant int"},
+				{File: "./main.go", Line: 8, Column: 2, Message: "x declared and not used"},
+			},
+		},
+		{
+			name: "continuation before diagnostics",
+			output: "\tunexpected\n" +
+				"./main.go:1:1: expected 'package'\n",
+			want: []*Diagnostic{
+				{File: "./main.go", Line: 1, Column: 1, Message: "expected 'package'"},
+			},
+		},
+		{
+			name:   "link errors",
+			output: "# example.com/app\n/usr/bin/ld: cannot find -lfoo\n",
+			want:   nil,
+		},
+	}
+	for _, tt := range tests {
+		got := ParseDiagnostics([]byte(tt.output))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ParseDiagnostics() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package fresher
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	ChangeCreated  = "created"
+	ChangeModified = "modified"
+	ChangeRemoved  = "removed"
+	ChangeRenamed  = "renamed"
+)
+
+// changeKinds is the order of the kinds in the summary.
+var changeKinds = []string{ChangeCreated, ChangeModified, ChangeRemoved, ChangeRenamed}
+
+// ChangesFileEnv is the environment variable of the hooks, the path to the file listing the changes as `<kind>\t<path>` lines.
+const ChangesFileEnv = "FRESHER_CHANGES_FILE"
+
+// ChangeSet is the kind of the change by the path, merged from the events while waiting for the interval.
+type ChangeSet map[string]string
+
+// add merges the operation into the change of the path. a file created and then removed is dropped,
+// and a file removed or renamed and then created, which is how some editors save, is modified.
+func (c ChangeSet) add(name string, op fsnotify.Op) {
+	prev, exists := c[name]
+	switch {
+	case op&(fsnotify.Remove|fsnotify.Rename) != 0:
+		if prev == ChangeCreated {
+			delete(c, name)
+			return
+		}
+		if op&fsnotify.Remove == fsnotify.Remove {
+			c[name] = ChangeRemoved
+			return
+		}
+		c[name] = ChangeRenamed
+	case op&fsnotify.Create == fsnotify.Create:
+		switch {
+		case !exists:
+			c[name] = ChangeCreated
+		case prev == ChangeRemoved || prev == ChangeRenamed:
+			c[name] = ChangeModified
+		}
+	case op&fsnotify.Write == fsnotify.Write:
+		if !exists {
+			c[name] = ChangeModified
+		}
+	}
+}
+
+// Paths returns the changed paths in order.
+func (c ChangeSet) Paths() []string {
+	paths := make([]string, 0, len(c))
+	for name := range c {
+		paths = append(paths, name)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Summary counts the changes by the kind, e.g. `2 created, 298 modified`.
+func (c ChangeSet) Summary() string {
+	counts := map[string]int{}
+	for _, kind := range c {
+		counts[kind]++
+	}
+	var summary []string
+	for _, kind := range changeKinds {
+		if counts[kind] > 0 {
+			summary = append(summary, fmt.Sprintf("%d %s", counts[kind], kind))
+		}
+	}
+	return strings.Join(summary, ", ")
+}
+
+// writeFile writes the changes to a temporary file for the hooks.
+func (c ChangeSet) writeFile() (string, error) {
+	f, err := ioutil.TempFile("", "fresher-changes-")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	for _, name := range c.Paths() {
+		fmt.Fprintf(w, "%s\t%s\n", c[name], name)
+	}
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+	return f.Name(), nil
+}
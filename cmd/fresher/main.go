@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/jessevdk/go-flags"
@@ -8,7 +9,8 @@ import (
 )
 
 type Option struct {
-	Start StartCommand `description:"start fresher and watch files" command:"start"`
+	Start    StartCommand    `description:"start fresher and watch files" command:"start"`
+	Validate ValidateCommand `description:"validate config yaml file" command:"validate"`
 }
 
 var opts Option
@@ -22,13 +24,29 @@ func (s *StartCommand) Execute(args []string) error {
 	if err != nil {
 		return err
 	}
-	fr := fresher.New(c.Options()...)
+	fr := fresher.New(append(c.Options(), fresher.ConfigFile(s.Config))...)
 	if err := fr.Watch(); err != nil {
 		return err
 	}
 	return nil
 }
 
+type ValidateCommand struct {
+	Config string `long:"config" short:"c" default:"fresher.yaml" description:"config yaml file name"`
+}
+
+func (v *ValidateCommand) Execute(args []string) error {
+	c, err := fresher.LoadConfig(v.Config)
+	if err != nil {
+		return err
+	}
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	fmt.Printf("%s is valid\n", v.Config)
+	return nil
+}
+
 func main() {
 	parser := flags.NewParser(&opts, flags.Default)
 	if _, err := parser.Parse(); err != nil {
@@ -38,6 +56,6 @@ func main() {
 			}
 			parser.WriteHelp(os.Stdout)
 		}
+		os.Exit(1)
 	}
-
 }
@@ -1,13 +1,15 @@
 package fresher
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
 	"os/exec"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/goccy/go-yaml"
 )
@@ -18,6 +20,17 @@ type Executor interface {
 	Kill() error
 }
 
+// ExitError is returned by Executor when the synchronous command exits with non-zero code.
+type ExitError struct {
+	Name   string
+	Code   int
+	Stderr []byte
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("%s exited with code %d", e.Name, e.Code)
+}
+
 type DockerCommand struct {
 	*Command
 	binPath string
@@ -49,35 +62,82 @@ func (c *DockerCommand) Exec() error {
 	return nil
 }
 
+func (c *DockerCommand) signal(pid string, sig Signal) error {
+	cmd := &Command{
+		Name: "docker",
+		Arg:  []string{"exec", c.host.LocationName, "kill", "-s", sig.Name(), pid},
+	}
+	return cmd.ExecContext(contextWithLog(context.Background(), c.logger()))
+}
+
+func (c *DockerCommand) isRunning(pid string) bool {
+	cmd := &Command{
+		Name: "docker",
+		Arg:  []string{"exec", c.host.LocationName, "kill", "-0", pid},
+	}
+	return cmd.build(context.Background()).Run() == nil
+}
+
+func (c *DockerCommand) waitExit(pid string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if !c.isRunning(pid) {
+			return true
+		}
+		time.Sleep(stopPollInterval)
+	}
+	return !c.isRunning(pid)
+}
+
 func (c *DockerCommand) Kill() error {
+	if c.proc == nil {
+		return nil
+	}
 	pidCommand := &Command{
 		Name: "docker",
 		Arg:  []string{"exec", c.host.LocationName, "pidof", "-s", c.binPath},
 	}
-	pid, err := pidCommand.build(context.Background()).Output()
+	out, err := pidCommand.build(context.Background()).Output()
 	if err != nil {
 		return err
 	}
-	cmd := &Command{
-		Name: "docker",
-		Arg:  []string{"exec", c.host.LocationName, "kill", "-9", strings.Split(string(pid), "\n")[0]},
-	}
-	log.Info(fmt.Sprintf("Kill Exec Process Inside Docker [%d]", c.proc.Pid))
-	if err := cmd.Exec(); err != nil {
+	pid := strings.Split(string(out), "\n")[0]
+	c.logger().Info(fmt.Sprintf("Send %s to Exec Process Inside Docker [%s]", c.StopSignal, pid))
+	if err := c.signal(pid, c.StopSignal); err != nil {
 		return err
 	}
+	if !c.StopSignal.IsKill() && !c.waitExit(pid, c.stopTimeout()) {
+		c.logger().Info(fmt.Sprintf("Kill Exec Process Inside Docker [%s] after %s", pid, c.stopTimeout()))
+		if err := c.signal(pid, SignalKILL); err != nil {
+			return err
+		}
+	}
 	if err := c.Command.Kill(); err != nil {
 		return err
 	}
 	return nil
 }
 
+const (
+	defaultStopTimeout = 5 * time.Second
+	stopPollInterval   = 100 * time.Millisecond
+)
+
 type Command struct {
-	Name    string
-	Arg     []string
-	Environ []string
-	IsAsync bool
-	proc    *os.Process
+	Name        string         `yaml:"name"`
+	Arg         []string       `yaml:"arg"`
+	Environ     []string       `yaml:"env"`
+	IsAsync     bool           `yaml:"async"`
+	When        string         `yaml:"when"`
+	Shell       bool           `yaml:"shell"`
+	StopSignal  Signal         `yaml:"-"`
+	StopTimeout time.Duration  `yaml:"-"`
+	Restart     *RestartPolicy `yaml:"-"`
+	proc        *os.Process
+	state       *os.ProcessState
+	exited      chan struct{}
+	// log is the logger of the running process to report its stop, since the commands are shared by the services.
+	log *Log
 }
 
 func (c *Command) UnmarshalYAML(b []byte) error {
@@ -86,17 +146,19 @@ func (c *Command) UnmarshalYAML(b []byte) error {
 		Arg     []string `yaml:"arg"`
 		Environ []string `yaml:"env"`
 		IsAsync bool     `yaml:"async"`
+		When    string   `yaml:"when"`
+		Shell   bool     `yaml:"shell"`
 	}{}
 	if err := yaml.Unmarshal(b, &st); err != nil {
 		var command string
 		if err := yaml.Unmarshal(b, &command); err != nil {
 			return err
 		}
-		words := strings.Split(command, " ")
+		words, err := splitWords(command)
+		if err != nil {
+			return err
+		}
 		for _, word := range words {
-			if word == "" {
-				continue
-			}
 			if c.Name == "" {
 				c.Name = word
 			} else {
@@ -110,16 +172,88 @@ func (c *Command) UnmarshalYAML(b []byte) error {
 	c.Arg = st.Arg
 	c.Environ = st.Environ
 	c.IsAsync = st.IsAsync
+	c.When = st.When
+	c.Shell = st.Shell
+	return nil
+}
+
+// expandEnv leaves name and arg as they are, so that the shell expands the variables of the hooks in them.
+func (c *Command) expandEnv(path string) error {
+	if err := (*Environ)(&c.Environ).expandEnv(fieldPath(path, "env")); err != nil {
+		return err
+	}
+	when, err := expandEnv(c.When)
+	if err != nil {
+		return fmt.Errorf("%s: %v", fieldPath(path, "when"), err)
+	}
+	c.When = when
 	return nil
 }
 
 func (c *Command) build(ctx context.Context) *exec.Cmd {
-	cmd := exec.CommandContext(ctx, c.Name, c.Arg...)
+	return c.buildWith(ctx, c.Name, c.Arg)
+}
+
+// buildWith runs the name and arg by the shell of ctx if Shell is true.
+// the name is the command line for the shell, and each arg is quoted to be passed as is.
+func (c *Command) buildWith(ctx context.Context, name string, arg []string) *exec.Cmd {
+	if c.Shell {
+		line := []string{name}
+		for _, a := range arg {
+			line = append(line, shellQuote(a))
+		}
+		name, arg = shellFromContext(ctx).command(strings.Join(line, " "))
+	}
+	cmd := exec.CommandContext(ctx, name, arg...)
 	cmd.Env = c.Environ
 	return cmd
 }
 
+// buildHook renders the templates in the arg and adds the environment variables if the command runs as a hook.
+func (c *Command) buildHook(ctx context.Context) (*exec.Cmd, error) {
+	hook := hookFromContext(ctx)
+	if hook == nil {
+		return c.build(ctx), nil
+	}
+	arg, err := hook.expandArg(c.Arg)
+	if err != nil {
+		return nil, err
+	}
+	name := c.Name
+	// the name is also a part of the command line for the shell, where the file names are quoted.
+	if c.Shell {
+		line, err := hook.quoted().expandArg([]string{c.Name})
+		if err != nil {
+			return nil, err
+		}
+		name = strings.Join(line, " ")
+	}
+	cmd := c.buildWith(ctx, name, arg)
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(append([]string{}, cmd.Env...), hook.environ()...)
+	return cmd, nil
+}
+
+func (c *Command) logger() *Log {
+	if c.log != nil {
+		return c.log
+	}
+	return log
+}
+
 func (c *Command) ExecContext(ctx context.Context) error {
+	if hook := hookFromContext(ctx); hook != nil {
+		ok, err := hook.matches(c.When)
+		if err != nil {
+			return fmt.Errorf("invalid when %s: %v", c.When, err)
+		}
+		if !ok {
+			logFromContext(ctx).Info(fmt.Sprintf("Skip %s, no changed file matches %s", c.Name, c.When))
+			return nil
+		}
+	}
 	if !c.IsAsync {
 		if err := c.runSync(ctx); err != nil {
 			return err
@@ -140,66 +274,135 @@ func (c *Command) Exec() error {
 	return nil
 }
 
+func (c *Command) stopTimeout() time.Duration {
+	if c.StopTimeout > 0 {
+		return c.StopTimeout
+	}
+	return defaultStopTimeout
+}
+
+// waitExit waits until both the process and the rest of its process group have exited.
+func (c *Command) waitExit(timeout time.Duration) bool {
+	deadline := time.After(timeout)
+	select {
+	case <-c.exited:
+	case <-deadline:
+		return false
+	}
+	for isProcessGroupAlive(c.proc) {
+		select {
+		case <-deadline:
+			return false
+		case <-time.After(stopPollInterval):
+		}
+	}
+	return true
+}
+
 func (c *Command) Kill() error {
 	if c.proc == nil {
 		return nil
 	}
-	log.Info(fmt.Sprintf("Kill Exec Process [%d]", c.proc.Pid))
-	if err := c.proc.Kill(); err != nil {
+	defer func() {
+		c.proc = nil
+	}()
+	if c.StopSignal.IsKill() {
+		c.logger().Info(fmt.Sprintf("Kill Exec Process [%d]", c.proc.Pid))
+	} else {
+		c.logger().Info(fmt.Sprintf("Send %s to Exec Process [%d]", c.StopSignal, c.proc.Pid))
+	}
+	if err := signalProcessGroup(c.proc, c.StopSignal.Syscall()); err != nil {
+		// some platforms cannot send signals other than kill, so fall back to it.
+		if err := signalProcessGroup(c.proc, syscall.SIGKILL); err != nil {
+			return nil
+		}
+	}
+	if c.waitExit(c.stopTimeout()) {
+		c.logExit()
 		return nil
 	}
+	c.logger().Info(fmt.Sprintf("Kill Exec Process [%d] after %s", c.proc.Pid, c.stopTimeout()))
+	if err := signalProcessGroup(c.proc, syscall.SIGKILL); err != nil {
+		return nil
+	}
+	if !c.waitExit(c.stopTimeout()) {
+		c.logger().Error(fmt.Sprintf("Exec Process [%d] is still alive after kill", c.proc.Pid))
+		return nil
+	}
+	c.logExit()
 	return nil
 }
 
-func (c *Command) runSync(ctx context.Context) error {
-	cmd := c.build(ctx)
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return err
+// Exited returns nil unless the command has been started asynchronously.
+func (c *Command) Exited() <-chan struct{} {
+	return c.exited
+}
+
+func (c *Command) ProcessState() *os.ProcessState {
+	return c.state
+}
+
+func (c *Command) RestartPolicy() *RestartPolicy {
+	return c.Restart
+}
+
+func (c *Command) logExit() {
+	if c.state == nil {
+		return
 	}
-	stderr, err := cmd.StderrPipe()
+	c.logger().Info(fmt.Sprintf("Exit Process [%d] (%s)", c.proc.Pid, c.state))
+}
+
+func (c *Command) runSync(ctx context.Context) error {
+	cmd, err := c.buildHook(ctx)
 	if err != nil {
 		return err
 	}
+	var stderr bytes.Buffer
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
 	if err := cmd.Start(); err != nil {
 		return err
 	}
-	if _, err := io.Copy(os.Stdout, stdout); err != nil {
-		return err
-	}
-	errBuf, err := ioutil.ReadAll(stderr)
-	if err != nil {
+	logFromContext(ctx).Info(fmt.Sprintf("Run Process [%d]", cmd.Process.Pid))
+	if err := cmd.Wait(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return &ExitError{
+				Name:   c.Name,
+				Code:   exitErr.ExitCode(),
+				Stderr: stderr.Bytes(),
+			}
+		}
 		return err
 	}
-	if len(errBuf) > 0 {
-		log.Error(string(errBuf))
-		return fmt.Errorf("failed to build: [%s]", string(errBuf))
-	}
-	log.Info(fmt.Sprintf("Run Process [%d]", cmd.Process.Pid))
 	return nil
 }
 
 func (c *Command) runAsync(ctx context.Context) error {
-	cmd := c.build(ctx)
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		return err
-	}
-
-	stdout, err := cmd.StdoutPipe()
+	cmd, err := c.buildHook(ctx)
 	if err != nil {
 		return err
 	}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	setProcessGroup(cmd)
 
-	log.Info("Waiting...")
+	l := logFromContext(ctx)
+	l.Info("Waiting...")
 	if err := cmd.Start(); err != nil {
 		return err
 	}
 
-	go io.Copy(os.Stdout, stdout)
-	go io.Copy(os.Stderr, stderr)
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		c.state = cmd.ProcessState
+		close(exited)
+	}()
 
 	c.proc = cmd.Process
-	log.Info(fmt.Sprintf("Run Process [%d]", cmd.Process.Pid))
+	c.exited = exited
+	c.log = l
+	l.Info(fmt.Sprintf("Run Process [%d]", cmd.Process.Pid))
 	return nil
 }
@@ -0,0 +1,84 @@
+package fresher
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// prSetChildSubreaper is PR_SET_CHILD_SUBREAPER of prctl.
+const prSetChildSubreaper = 36
+
+const grandchildMain = `package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+func main() {
+	cmd := exec.Command("sleep", "1000")
+	if err := cmd.Start(); err != nil {
+		panic(err)
+	}
+	f, err := os.Create(os.Getenv("PID_FILE"))
+	if err != nil {
+		panic(err)
+	}
+	fmt.Fprintln(f, cmd.Process.Pid)
+	f.Close()
+	time.Sleep(time.Hour)
+}
+`
+
+func TestServiceRestartKillsGrandchild(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fresher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	main := filepath.Join(dir, "main.go")
+	if err := ioutil.WriteFile(main, []byte(grandchildMain), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pidFile := filepath.Join(dir, "pid")
+	build := &BuildConfig{
+		Target:      main,
+		Output:      filepath.Join(dir, "app"),
+		RunEnviron:  Environ{"PID_FILE=" + pidFile},
+		StopSignal:  SignalTERM,
+		StopTimeout: Duration(10 * time.Second),
+	}
+	// the grandchildren are left as zombies in the process group as under the init which does not reap them.
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetChildSubreaper, 1, 0); errno != 0 {
+		t.Skipf("failed to become subreaper: %v", errno)
+	}
+	defer syscall.RawSyscall(syscall.SYS_PRCTL, prSetChildSubreaper, 0, 0)
+	s := newService("", build, nil, nil, New().opt)
+	defer s.shutdown()
+
+	s.restart(nil)
+	pid := waitPid(t, pidFile)
+	if err := os.Remove(pidFile); err != nil {
+		t.Fatal(err)
+	}
+	started := time.Now()
+	s.restart(nil)
+	waitPid(t, pidFile)
+	// the zombies left in the process group must not hold the restart until stop_timeout.
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("restart took %s", elapsed)
+	}
+	var status syscall.WaitStatus
+	if _, err := syscall.Wait4(pid, &status, syscall.WNOHANG, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !status.Exited() && !status.Signaled() {
+		t.Errorf("grandchild %d is still alive after restart", pid)
+	}
+}
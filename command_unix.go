@@ -0,0 +1,75 @@
+//go:build !windows
+// +build !windows
+
+package fresher
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+func signalProcessGroup(proc *os.Process, sig syscall.Signal) error {
+	return syscall.Kill(-proc.Pid, sig)
+}
+
+// isProcessGroupAlive reports whether any process of the group is still running.
+// the zombies are not counted, since they are never reaped under the init which does not reap them, such as in a bare container.
+func isProcessGroupAlive(proc *os.Process) bool {
+	if syscall.Kill(-proc.Pid, 0) != nil {
+		return false
+	}
+	alive, ok := hasRunningMember(proc.Pid)
+	if !ok {
+		return true
+	}
+	return alive
+}
+
+// hasRunningMember finds the processes of the group except the zombies from /proc. ok is false if /proc is not available.
+func hasRunningMember(pgid int) (alive bool, ok bool) {
+	dir, err := os.Open("/proc")
+	if err != nil {
+		return false, false
+	}
+	defer dir.Close()
+	names, err := dir.Readdirnames(-1)
+	if err != nil {
+		return false, false
+	}
+	group := strconv.Itoa(pgid)
+	for _, name := range names {
+		if _, err := strconv.Atoi(name); err != nil {
+			continue
+		}
+		stat, err := ioutil.ReadFile(filepath.Join("/proc", name, "stat"))
+		if err != nil {
+			continue
+		}
+		// the fields after the command name in parentheses are state, ppid and pgrp.
+		idx := bytes.LastIndexByte(stat, ')')
+		if idx < 0 {
+			continue
+		}
+		fields := strings.Fields(string(stat[idx+1:]))
+		if len(fields) < 3 || fields[2] != group {
+			continue
+		}
+		if fields[0] != "Z" && fields[0] != "X" {
+			return true, true
+		}
+	}
+	return false, true
+}
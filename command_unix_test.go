@@ -0,0 +1,71 @@
+//go:build !windows
+// +build !windows
+
+package fresher
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func waitPid(t *testing.T, pidFile string) int {
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		if b, err := ioutil.ReadFile(pidFile); err == nil && strings.HasSuffix(string(b), "\n") {
+			pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return pid
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("grandchild is not started")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// waitGone waits for the process to exit. it may remain as a zombie for a moment until it is reaped by init.
+func waitGone(t *testing.T, pid int) {
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		err := syscall.Kill(pid, 0)
+		if err == syscall.ESRCH {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("grandchild %d is still alive: %v", pid, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCommandKillGrandchild(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fresher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pidFile := filepath.Join(dir, "pid")
+	cmd := &Command{
+		Name:       "sh",
+		Arg:        []string{"-c", "sleep 1000 & echo $! > " + pidFile + "; wait"},
+		IsAsync:    true,
+		StopSignal: SignalTERM,
+	}
+	if err := cmd.ExecContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	pid := waitPid(t, pidFile)
+
+	if err := cmd.Kill(); err != nil {
+		t.Fatal(err)
+	}
+	waitGone(t, pid)
+}
@@ -0,0 +1,21 @@
+package fresher
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// signalProcessGroup only stops the process itself because windows does not have process groups like unix.
+func signalProcessGroup(proc *os.Process, sig syscall.Signal) error {
+	if sig == syscall.SIGKILL {
+		return proc.Kill()
+	}
+	return proc.Signal(sig)
+}
+
+func isProcessGroupAlive(proc *os.Process) bool {
+	return false
+}
@@ -4,9 +4,11 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"reflect"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,22 +16,43 @@ import (
 )
 
 type Config struct {
-	Build       *BuildConfig     `yaml:"build"`
-	Paths       []*WatcherConfig `yaml:"path"`
-	ExcludePath *GlobalExclude   `yaml:"exclude"`
-	Extensions  Extensions       `yaml:"extension"`
-	Interval    time.Duration    `yaml:"interval"`
+	Build        *BuildConfig     `yaml:"build"`
+	Paths        []*WatcherConfig `yaml:"path"`
+	Actions      Actions          `yaml:"actions"`
+	ExcludePath  *GlobalExclude   `yaml:"exclude"`
+	GitIgnore    bool             `yaml:"gitignore"`
+	DockerIgnore bool             `yaml:"dockerignore"`
+	Extensions   Extensions       `yaml:"extension"`
+	Interval     Duration         `yaml:"interval"`
+	Debounce     *Debounce        `yaml:"debounce"`
+	Shell        Shell            `yaml:"shell"`
+	Proxy        *ProxyConfig     `yaml:"proxy"`
+	Services     []*ServiceConfig `yaml:"services"`
+	filename     string
+	source       []byte
 }
 
 type BuildConfig struct {
-	Target         string     `yaml:"target"`
-	Host           *Host      `yaml:"host"`
-	Output         string     `yaml:"output"`
-	Environ        Environ    `yaml:"env"`
-	Arg            []string   `yaml:"arg"`
-	WithoutRun     bool       `yaml:"without_run"`
-	BeforeCommands []*Command `yaml:"before"`
-	AfterCommands  []*Command `yaml:"after"`
+	Target         string          `yaml:"target"`
+	Host           *Host           `yaml:"host"`
+	Output         string          `yaml:"output"`
+	Environ        Environ         `yaml:"env"`
+	BuildEnviron   Environ         `yaml:"build_env"`
+	RunEnviron     Environ         `yaml:"run_env"`
+	EnvFiles       EnvFiles        `yaml:"env_file"`
+	Arg            []string        `yaml:"arg"`
+	WithoutRun     bool            `yaml:"without_run"`
+	KeepOnFailure  bool            `yaml:"keep_on_failure"`
+	DepsOnly       bool            `yaml:"deps_only"`
+	AutoWatch      bool            `yaml:"auto_watch"`
+	StopSignal     Signal          `yaml:"stop_signal"`
+	StopTimeout    Duration        `yaml:"stop_timeout"`
+	Ready          *ReadinessProbe `yaml:"ready"`
+	Restart        *RestartPolicy  `yaml:"restart"`
+	BeforeCommands []*Command      `yaml:"before"`
+	AfterCommands  []*Command      `yaml:"after"`
+	name           string
+	envFileEnviron []string
 }
 
 func (bc *BuildConfig) runBinaryPath() string {
@@ -37,24 +60,30 @@ func (bc *BuildConfig) runBinaryPath() string {
 		return bc.Output
 	}
 	name := "fresher_run"
-	var isIncludeGOOSEnv bool
-	for _, env := range bc.Environ {
-		if strings.Contains(env, "GOOS") {
-			isIncludeGOOSEnv = true
-			if strings.Contains(env, "windows") {
-				name += ".exe"
-			}
-			break
+	if bc.name != "" {
+		name = fmt.Sprintf("%s_%s", name, bc.name)
+	}
+	// build_env is given to go build after env, so its GOOS wins.
+	goos := runtime.GOOS
+	for _, env := range append(append([]string{}, bc.Environ...), bc.BuildEnviron...) {
+		if strings.HasPrefix(env, "GOOS=") {
+			goos = strings.TrimPrefix(env, "GOOS=")
 		}
 	}
-	if !isIncludeGOOSEnv && runtime.GOOS == "windows" {
+	if goos == "windows" {
 		name += ".exe"
 	}
 	return filepath.Join(os.TempDir(), name)
 }
 
-func (bc *BuildConfig) buildArg() []string {
-	arg := []string{"build", "-o", bc.runBinaryPath()}
+// stagingBinaryPath is the output of the build in keep_on_failure mode. it is renamed to runBinaryPath only if the build succeeds.
+func (bc *BuildConfig) stagingBinaryPath() string {
+	dir, name := filepath.Split(bc.runBinaryPath())
+	return filepath.Join(dir, fmt.Sprintf(".fresher_staging_%s", name))
+}
+
+func (bc *BuildConfig) buildArg(output string) []string {
+	arg := []string{"build", "-o", output}
 	if len(bc.Arg) > 0 {
 		arg = append(arg, bc.Arg...)
 	}
@@ -63,51 +92,114 @@ func (bc *BuildConfig) buildArg() []string {
 }
 
 func (bc *BuildConfig) Commands() []Executor {
-	commands := []Executor{
-		bc.BuildCommand(),
+	return append(bc.buildCommands(bc.runBinaryPath()), bc.ReleaseCommands()...)
+}
+
+// StagingCommands returns the commands that build the binary into the staging path instead of the path to run.
+func (bc *BuildConfig) StagingCommands() []Executor {
+	return bc.buildCommands(bc.stagingBinaryPath())
+}
+
+func (bc *BuildConfig) buildCommands(output string) []Executor {
+	commands := make([]Executor, 0, len(bc.BeforeCommands)+1)
+	for _, cmd := range bc.BeforeCommands {
+		commands = append(commands, cmd)
 	}
+	return append(commands, bc.buildCommand(output))
+}
+
+// ReleaseCommands returns the commands that run the built binary, wait for it to be ready and the after hooks.
+func (bc *BuildConfig) ReleaseCommands() []Executor {
+	var commands []Executor
 	if cmd := bc.RunCommand(); cmd != nil {
 		commands = append(commands, cmd)
+		if bc.Ready != nil {
+			commands = append(commands, &ReadyCommand{probe: bc.Ready})
+		}
 	}
-	if len(bc.BeforeCommands) > 0 {
-		cmds := make([]Executor, len(bc.BeforeCommands))
-		for idx, cmd := range bc.BeforeCommands {
-			cmds[idx] = cmd
+	for _, cmd := range bc.AfterCommands {
+		commands = append(commands, cmd)
+	}
+	return commands
+}
+
+// isHook reports whether cmd is one of the before and after commands.
+func (bc *BuildConfig) isHook(cmd Executor) bool {
+	for _, hook := range bc.BeforeCommands {
+		if Executor(hook) == cmd {
+			return true
 		}
-		commands = append(cmds, commands...)
 	}
-	if len(bc.AfterCommands) > 0 {
-		cmds := make([]Executor, len(bc.AfterCommands))
-		for idx, cmd := range bc.AfterCommands {
-			cmds[idx] = cmd
+	for _, hook := range bc.AfterCommands {
+		if Executor(hook) == cmd {
+			return true
 		}
-		commands = append(commands, cmds...)
 	}
-	return commands
+	return false
 }
 
 func (bc *BuildConfig) BuildCommand() Executor {
-	cmd := exec.Command("go", bc.buildArg()...)
-	cmd.Env = append(os.Environ(), bc.Environ...)
-	return &Command{
-		Name:    "go",
-		Arg:     bc.buildArg(),
-		Environ: append(os.Environ(), bc.Environ...),
-		IsAsync: false,
+	return bc.buildCommand(bc.runBinaryPath())
+}
+
+func (bc *BuildConfig) buildCommand(output string) Executor {
+	return &BuildCommand{
+		Command: &Command{
+			Name:    "go",
+			Arg:     bc.buildArg(output),
+			Environ: bc.buildEnviron(),
+			IsAsync: false,
+		},
 	}
 }
 
+// ReleaseStagingBinary replaces the binary to run with the one built by StagingCommands.
+func (bc *BuildConfig) ReleaseStagingBinary() error {
+	if err := os.Rename(bc.stagingBinaryPath(), bc.runBinaryPath()); err != nil {
+		return fmt.Errorf("failed to release built binary: %v", err)
+	}
+	return nil
+}
+
+// buildEnviron is the environment of go build. env and build_env are appended to the one of fresher.
+func (bc *BuildConfig) buildEnviron() []string {
+	environ := append(os.Environ(), bc.Environ...)
+	return append(environ, bc.BuildEnviron...)
+}
+
+// runEnviron is the variables added to the environment of the binary, env_file and then run_env.
+func (bc *BuildConfig) runEnviron() []string {
+	environ := append([]string{}, bc.envFileEnviron...)
+	return append(environ, bc.RunEnviron...)
+}
+
+// LoadEnvFiles reads env_file again so that the next RunCommand uses the latest values.
+func (bc *BuildConfig) LoadEnvFiles() error {
+	environ, err := bc.EnvFiles.Load()
+	if err != nil {
+		return err
+	}
+	bc.envFileEnviron = environ
+	return nil
+}
+
 func (bc *BuildConfig) RunCommand() Executor {
 	if bc.WithoutRun {
 		return nil
 	}
+	environ := bc.runEnviron()
+	cmd := &Command{
+		Name:        bc.runBinaryPath(),
+		Environ:     append(os.Environ(), environ...),
+		IsAsync:     true,
+		StopSignal:  bc.StopSignal,
+		StopTimeout: time.Duration(bc.StopTimeout),
+		Restart:     bc.Restart,
+	}
 	if bc.Host == nil {
-		return &Command{
-			Name:    bc.runBinaryPath(),
-			IsAsync: true,
-		}
+		return cmd
 	}
-	return bc.Host.RunCommand(bc.runBinaryPath())
+	return bc.Host.Command(cmd, environ...)
 }
 
 type Environ []string
@@ -117,8 +209,60 @@ func (e *Environ) UnmarshalYAML(b []byte) error {
 	if err := yaml.Unmarshal(b, &m); err != nil {
 		return err
 	}
-	for k, v := range m {
-		*e = append(*e, fmt.Sprintf("%s=%s", k, v))
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		*e = append(*e, fmt.Sprintf("%s=%s", k, m[k]))
+	}
+	return nil
+}
+
+// expandEnv reports the key of the variable instead of the index for the error.
+func (e *Environ) expandEnv(path string) error {
+	for idx, env := range *e {
+		key, value := env, ""
+		if i := strings.Index(env, "="); i >= 0 {
+			key, value = env[:i], env[i+1:]
+		}
+		expanded, err := expandEnv(value)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %v", path, key, err)
+		}
+		(*e)[idx] = fmt.Sprintf("%s=%s", key, expanded)
+	}
+	return nil
+}
+
+// Duration accepts both an integer as seconds and a string with units such as `500ms`.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalYAML(b []byte) error {
+	var v interface{}
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	switch vv := v.(type) {
+	case int64:
+		*d = Duration(time.Duration(vv) * time.Second)
+	case uint64:
+		*d = Duration(time.Duration(vv) * time.Second)
+	case float64:
+		*d = Duration(vv * float64(time.Second))
+	case string:
+		if sec, err := strconv.Atoi(vv); err == nil {
+			*d = Duration(time.Duration(sec) * time.Second)
+			return nil
+		}
+		dur, err := time.ParseDuration(vv)
+		if err != nil {
+			return err
+		}
+		*d = Duration(dur)
+	default:
+		return fmt.Errorf("invalid duration: %s", string(b))
 	}
 	return nil
 }
@@ -138,7 +282,6 @@ func (a ArgDecoders) Argument() []string {
 }
 
 func (a *ArgDecoder) UnmarshalYAML(b []byte) error {
-	fmt.Println(string(b))
 	m := make(map[string]string)
 	if err := yaml.Unmarshal(b, &m); err != nil {
 		var s string
@@ -156,29 +299,52 @@ func (a *ArgDecoder) UnmarshalYAML(b []byte) error {
 
 func (bc *BuildConfig) UnmarshalYAML(b []byte) error {
 	st := struct {
-		Target         string      `yaml:"target"`
-		Host           *Host       `yaml:"host"`
-		Output         string      `yaml:"output"`
-		Environ        Environ     `yaml:"env"`
-		Arg            ArgDecoders `yaml:"arg"`
-		WithoutRun     bool        `yaml:"without_run"`
-		BeforeCommands []*Command  `yaml:"before"`
-		AfterCommands  []*Command  `yaml:"after"`
+		Target         string          `yaml:"target"`
+		Host           *Host           `yaml:"host"`
+		Output         string          `yaml:"output"`
+		Environ        Environ         `yaml:"env"`
+		BuildEnviron   Environ         `yaml:"build_env"`
+		RunEnviron     Environ         `yaml:"run_env"`
+		EnvFiles       EnvFiles        `yaml:"env_file"`
+		Arg            ArgDecoders     `yaml:"arg"`
+		WithoutRun     bool            `yaml:"without_run"`
+		KeepOnFailure  bool            `yaml:"keep_on_failure"`
+		DepsOnly       bool            `yaml:"deps_only"`
+		AutoWatch      bool            `yaml:"auto_watch"`
+		StopSignal     Signal          `yaml:"stop_signal"`
+		StopTimeout    Duration        `yaml:"stop_timeout"`
+		Ready          *ReadinessProbe `yaml:"ready"`
+		Restart        *RestartPolicy  `yaml:"restart"`
+		BeforeCommands []*Command      `yaml:"before"`
+		AfterCommands  []*Command      `yaml:"after"`
 	}{}
-	if err := yaml.Unmarshal(b, &st); err != nil {
-		var target string
-		if err := yaml.Unmarshal(b, &target); err != nil {
-			return err
-		}
+	var v interface{}
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	if target, ok := v.(string); ok {
 		bc.Target = target
 		return nil
 	}
+	if err := yaml.Unmarshal(b, &st); err != nil {
+		return err
+	}
 	bc.Target = st.Target
 	bc.Host = st.Host
 	bc.Output = st.Output
 	bc.Environ = st.Environ
+	bc.BuildEnviron = st.BuildEnviron
+	bc.RunEnviron = st.RunEnviron
+	bc.EnvFiles = st.EnvFiles
 	bc.Arg = st.Arg.Argument()
 	bc.WithoutRun = st.WithoutRun
+	bc.KeepOnFailure = st.KeepOnFailure
+	bc.DepsOnly = st.DepsOnly
+	bc.AutoWatch = st.AutoWatch
+	bc.StopSignal = st.StopSignal
+	bc.StopTimeout = st.StopTimeout
+	bc.Ready = st.Ready
+	bc.Restart = st.Restart
 	bc.BeforeCommands = st.BeforeCommands
 	bc.AfterCommands = st.AfterCommands
 	return nil
@@ -193,6 +359,11 @@ func LoadConfig(filename string) (*Config, error) {
 	if err := yaml.Unmarshal(b, &conf); err != nil {
 		return nil, err
 	}
+	if err := expandEnvFields(reflect.ValueOf(&conf), ""); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %v", filename, err)
+	}
+	conf.filename = filename
+	conf.source = b
 	return &conf, nil
 }
 
@@ -207,14 +378,35 @@ func (c *Config) Options() []OptionFunc {
 	if len(c.Paths) > 0 {
 		funcs = append(funcs, WatchConfigs(c.Paths))
 	}
+	if len(c.Actions) > 0 {
+		funcs = append(funcs, WatchActions(c.Actions))
+	}
 	if c.ExcludePath != nil {
 		funcs = append(funcs, GlobalExcludePath(c.ExcludePath))
 	}
+	if c.GitIgnore {
+		funcs = append(funcs, GitIgnore(c.GitIgnore))
+	}
+	if c.DockerIgnore {
+		funcs = append(funcs, DockerIgnore(c.DockerIgnore))
+	}
 	if len(c.Extensions) > 0 {
 		funcs = append(funcs, ExtensionPaths(c.Extensions))
 	}
 	if c.Interval > 0 {
-		funcs = append(funcs, WatchInterval(c.Interval*time.Second))
+		funcs = append(funcs, WatchInterval(time.Duration(c.Interval)))
+	}
+	if c.Debounce != nil {
+		funcs = append(funcs, WatchDebounce(c.Debounce))
+	}
+	if len(c.Shell) > 0 {
+		funcs = append(funcs, CommandShell(c.Shell))
+	}
+	if c.Proxy != nil {
+		funcs = append(funcs, ReverseProxy(c.Proxy))
+	}
+	if len(c.Services) > 0 {
+		funcs = append(funcs, Services(c.Services))
 	}
 	return funcs
 }
@@ -0,0 +1,107 @@
+package fresher
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/goccy/go-yaml"
+)
+
+const (
+	// DebounceTrailing performs the actions after no file is changed for the interval.
+	DebounceTrailing = "trailing"
+	// DebounceLeading performs the actions immediately, and the changes in the interval after them at the end of it.
+	DebounceLeading = "leading"
+)
+
+// Debounce is how to wait for the changed files before performing the actions.
+// MaxWait caps the waiting of the trailing strategy, not to be postponed forever by the files changed continuously.
+type Debounce struct {
+	Strategy string   `yaml:"strategy"`
+	MaxWait  Duration `yaml:"max_wait"`
+}
+
+func (d *Debounce) UnmarshalYAML(b []byte) error {
+	s := struct {
+		Strategy string   `yaml:"strategy"`
+		MaxWait  Duration `yaml:"max_wait"`
+	}{}
+	if err := yaml.Unmarshal(b, &s); err != nil {
+		var strategy string
+		if err := yaml.Unmarshal(b, &strategy); err != nil {
+			return err
+		}
+		d.Strategy = strategy
+		return nil
+	}
+	d.Strategy = s.Strategy
+	d.MaxWait = s.MaxWait
+	return nil
+}
+
+func (d *Debounce) strategy() string {
+	if d == nil || d.Strategy == "" {
+		return DebounceTrailing
+	}
+	return d.Strategy
+}
+
+func (d *Debounce) maxWait() time.Duration {
+	if d == nil {
+		return 0
+	}
+	return time.Duration(d.MaxWait)
+}
+
+func (d *Debounce) Validate() error {
+	switch d.strategy() {
+	case DebounceTrailing:
+		return nil
+	case DebounceLeading:
+		if d.maxWait() > 0 {
+			return fmt.Errorf("max_wait is available only for %s strategy", DebounceTrailing)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown debounce strategy %s", d.Strategy)
+}
+
+// debounce reports whether to record the changed file, and reserves fire according to the strategy.
+// f.mu must be held.
+func (f *Fresher) debounce(now time.Time) bool {
+	if f.opt.debounce.strategy() == DebounceLeading {
+		if now.Before(f.quietUntil) {
+			// the changes in the interval are performed once at the end of it.
+			if f.quietTimer == nil {
+				f.quietTimer = time.AfterFunc(f.quietUntil.Sub(now), f.fireQuiet)
+			}
+			return true
+		}
+		f.quietUntil = now.Add(f.opt.interval)
+		go f.fire()
+		return true
+	}
+	if len(f.pending) == 0 {
+		f.waitingSince = now
+	}
+	delay := f.opt.interval
+	if maxWait := f.opt.debounce.maxWait(); maxWait > 0 {
+		if rest := maxWait - now.Sub(f.waitingSince); rest < delay {
+			delay = rest
+		}
+	}
+	if f.timer != nil {
+		f.timer.Stop()
+	}
+	f.timer = time.AfterFunc(delay, f.fire)
+	return true
+}
+
+// fireQuiet performs the changes in the interval of the leading strategy, and starts the next interval.
+func (f *Fresher) fireQuiet() {
+	f.mu.Lock()
+	f.quietTimer = nil
+	f.quietUntil = time.Now().Add(f.opt.interval)
+	f.mu.Unlock()
+	f.fire()
+}
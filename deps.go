@@ -0,0 +1,259 @@
+package fresher
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type goModule struct {
+	Path string
+	Dir  string
+	Main bool
+}
+
+// goPackage is a part of the output of `go list -json`.
+type goPackage struct {
+	Dir        string
+	ImportPath string
+	Standard   bool
+	Module     *goModule
+	GoFiles    []string
+	CgoFiles   []string
+	EmbedFiles []string
+}
+
+func (p *goPackage) files() []string {
+	var files []string
+	for _, f := range append(append([]string{}, p.GoFiles...), p.CgoFiles...) {
+		files = append(files, filepath.Join(p.Dir, f))
+	}
+	return files
+}
+
+// isLocal reports whether the package is in the main module, not in the standard library or the module cache.
+// the package of go files given as the target has no module.
+func (p *goPackage) isLocal() bool {
+	if p.ImportPath == "command-line-arguments" {
+		return true
+	}
+	return !p.Standard && p.Module != nil && p.Module.Main
+}
+
+// listPackages runs `go list` with the flags and the environment of `go build`, such as -tags and GOOS,
+// so that the packages are the same as the ones compiled.
+func listPackages(target string, flags, environ []string) ([]*goPackage, error) {
+	var stdout, stderr bytes.Buffer
+	arg := append(append([]string{"list", "-e", "-deps", "-json"}, flags...), target)
+	cmd := exec.Command("go", arg...)
+	cmd.Env = environ
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to list packages of %s: %v: %s", target, err, stderr.String())
+	}
+	var packages []*goPackage
+	dec := json.NewDecoder(&stdout)
+	for {
+		var pkg goPackage
+		if err := dec.Decode(&pkg); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		packages = append(packages, &pkg)
+	}
+	return packages, nil
+}
+
+func parseImports(path string) (string, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.ImportsOnly)
+	if err != nil {
+		return "", err
+	}
+	imports := make([]string, 0, len(f.Imports))
+	for _, spec := range f.Imports {
+		imports = append(imports, spec.Path.Value)
+	}
+	sort.Strings(imports)
+	return strings.Join(imports, ","), nil
+}
+
+func absPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	return abs
+}
+
+// Dependencies is the set of package directories in the main module which the build target depends on.
+// it needs to be loaded again when go.mod or the imports of the dependent files are changed.
+type Dependencies struct {
+	target   string
+	flags    []string
+	environ  []string
+	mu       *sync.Mutex
+	packages []*goPackage
+	module   *goModule
+	dirs     map[string]struct{}
+	imports  map[string]string
+	dirty    bool
+	key      string
+	version  int
+}
+
+// NewDependencies takes the flags and the whole environment given to `go build`.
+func NewDependencies(target string, flags, environ []string) *Dependencies {
+	return &Dependencies{
+		target:  target,
+		flags:   flags,
+		environ: environ,
+		mu:      new(sync.Mutex),
+		dirty:   true,
+	}
+}
+
+// LoadIfNeeds runs `go list -deps` only if the dependencies may be changed since the last load.
+func (d *Dependencies) LoadIfNeeds() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.dirty {
+		return nil
+	}
+	packages, err := listPackages(d.target, d.flags, d.environ)
+	if err != nil {
+		return err
+	}
+	dirs := map[string]struct{}{}
+	imports := map[string]string{}
+	var (
+		locals []*goPackage
+		module *goModule
+		keys   []string
+	)
+	for _, pkg := range packages {
+		if !pkg.isLocal() {
+			continue
+		}
+		locals = append(locals, pkg)
+		if pkg.Module != nil {
+			module = pkg.Module
+		}
+		dirs[absPath(pkg.Dir)] = struct{}{}
+		keys = append(keys, pkg.Dir)
+		keys = append(keys, pkg.EmbedFiles...)
+		for _, file := range pkg.files() {
+			if imported, err := parseImports(file); err == nil {
+				imports[absPath(file)] = imported
+			}
+		}
+	}
+	d.packages = locals
+	d.module = module
+	d.dirs = dirs
+	d.imports = imports
+	d.dirty = false
+	if key := strings.Join(keys, ","); key != d.key {
+		d.key = key
+		d.version++
+	}
+	return nil
+}
+
+// Version is incremented every time the loaded packages or their embedded files are changed.
+func (d *Dependencies) Version() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.version
+}
+
+// WatchConfigs returns the configs watching the go files directly in the dependent packages,
+// their embedded files and go.mod/go.sum of the main module.
+func (d *Dependencies) WatchConfigs() []*WatcherConfig {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var configs []*WatcherConfig
+	configByDir := map[string]*WatcherConfig{}
+	config := func(dir string) *WatcherConfig {
+		if wc, exists := configByDir[dir]; exists {
+			return wc
+		}
+		wc := &WatcherConfig{
+			Name:  relPathFromWorkDir(dir),
+			files: map[string]struct{}{},
+		}
+		configByDir[dir] = wc
+		configs = append(configs, wc)
+		return wc
+	}
+	for _, pkg := range d.packages {
+		wc := config(pkg.Dir)
+		wc.flat = true
+		for _, file := range pkg.EmbedFiles {
+			wc.files[filepath.FromSlash(file)] = struct{}{}
+		}
+	}
+	if d.module != nil && d.module.Dir != "" {
+		wc := config(d.module.Dir)
+		wc.files["go.mod"] = struct{}{}
+		wc.files["go.sum"] = struct{}{}
+	}
+	sort.Slice(configs, func(i, j int) bool {
+		return configs[i].Name < configs[j].Name
+	})
+	return configs
+}
+
+func relPathFromWorkDir(path string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(wd, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, fmt.Sprintf("..%c", filepath.Separator)) {
+		return path
+	}
+	return rel
+}
+
+// IsDependent reports whether the change of path may affect the build target.
+// it also marks the dependencies to be loaded again if go.mod or the imports of path are changed.
+func (d *Dependencies) IsDependent(path string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch filepath.Base(path) {
+	case "go.mod", "go.sum", "go.work":
+		d.dirty = true
+		return true
+	}
+	if filepath.Ext(path) != ".go" || d.dirs == nil {
+		return true
+	}
+	if strings.HasSuffix(path, "_test.go") {
+		return false
+	}
+	abs := absPath(path)
+	if _, exists := d.dirs[filepath.Dir(abs)]; !exists {
+		return false
+	}
+	imported, err := parseImports(abs)
+	if err != nil {
+		return true
+	}
+	if d.imports[abs] != imported {
+		d.imports[abs] = imported
+		d.dirty = true
+	}
+	return true
+}
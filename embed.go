@@ -0,0 +1,202 @@
+package fresher
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const embedDirective = "//go:embed"
+
+// embedPatterns is the patterns of the //go:embed directives in a go file.
+type embedPatterns struct {
+	dir      string
+	patterns []string
+	wcs      []*WatcherConfig
+}
+
+// parseEmbedPatterns reads the //go:embed directives line by line since the file may be in the middle of editing.
+func parseEmbedPatterns(name string) ([]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var patterns []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, embedDirective+" ") && !strings.HasPrefix(line, embedDirective+"\t") {
+			continue
+		}
+		patterns = append(patterns, splitEmbedPatterns(strings.TrimPrefix(line, embedDirective))...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return patterns, nil
+}
+
+// splitEmbedPatterns splits the arguments of //go:embed by spaces, and unquotes "..." and `...`.
+func splitEmbedPatterns(args string) []string {
+	var (
+		patterns []string
+		current  strings.Builder
+		quote    rune
+		escaped  bool
+	)
+	flush := func() {
+		if current.Len() > 0 {
+			patterns = append(patterns, current.String())
+			current.Reset()
+		}
+	}
+	for _, r := range args {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case quote == '"' && r == '\\':
+			escaped = true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '"' || r == '`':
+			quote = r
+		case r == ' ' || r == '\t':
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	flush()
+	return patterns
+}
+
+func isHiddenEmbedName(name string) bool {
+	return strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")
+}
+
+// files returns the files embedded by the patterns.
+// the files in the embedded directories whose names begin with '.' or '_' are excluded unless the pattern has the prefix all:
+func (e *embedPatterns) files() []string {
+	var files []string
+	for _, pattern := range e.patterns {
+		all := strings.HasPrefix(pattern, "all:")
+		matches, err := filepath.Glob(filepath.Join(e.dir, filepath.FromSlash(strings.TrimPrefix(pattern, "all:"))))
+		if err != nil {
+			continue
+		}
+		for _, match := range matches {
+			filepath.Walk(match, func(name string, info os.FileInfo, err error) error {
+				if err != nil {
+					return nil
+				}
+				if name != match && !all && isHiddenEmbedName(info.Name()) {
+					if info.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if !info.IsDir() {
+					files = append(files, name)
+				}
+				return nil
+			})
+		}
+	}
+	return files
+}
+
+// Match reports whether the file is embedded by the patterns, which is used for the created files.
+func (e *embedPatterns) Match(name string) bool {
+	rel, err := filepath.Rel(e.dir, name)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	names := strings.Split(filepath.ToSlash(rel), "/")
+	for _, pattern := range e.patterns {
+		all := strings.HasPrefix(pattern, "all:")
+		pattern = strings.TrimPrefix(pattern, "all:")
+		for idx := range names {
+			matched, err := path.Match(pattern, strings.Join(names[:idx+1], "/"))
+			if err != nil || !matched {
+				continue
+			}
+			if all || !hasHiddenEmbedName(names[idx+1:]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasHiddenEmbedName(names []string) bool {
+	for _, name := range names {
+		if isHiddenEmbedName(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// watchEmbeds watches the files embedded by the go file with the WatcherConfigs watching the go file.
+func (w *WatcherPath) watchEmbeds(name string, wcs []*WatcherConfig, watcher *fsnotify.Watcher) error {
+	if filepath.Ext(name) != ".go" || strings.HasSuffix(name, "_test.go") {
+		return nil
+	}
+	patterns, err := parseEmbedPatterns(name)
+	if err != nil {
+		return err
+	}
+	if len(patterns) == 0 {
+		delete(w.embeds, name)
+		return nil
+	}
+	embeds := &embedPatterns{
+		dir:      filepath.Dir(name),
+		patterns: patterns,
+		wcs:      wcs,
+	}
+	w.embeds[name] = embeds
+	for _, file := range embeds.files() {
+		for _, wc := range wcs {
+			if err := w.watchEmbed(file, wc, watcher); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (w *WatcherPath) watchEmbed(name string, wc *WatcherConfig, watcher *fsnotify.Watcher) error {
+	for _, current := range w.watches[name] {
+		if current == wc {
+			return nil
+		}
+	}
+	if err := watcher.Add(filepath.Dir(name)); err != nil {
+		return err
+	}
+	log.WatchFile(name)
+	// the file may be ignored already if it is walked before the go file embedding it.
+	delete(w.ignores, name)
+	w.add(name, wc)
+	return nil
+}
+
+// embeddedConfigs returns the WatcherConfigs watching the go files which embed the file.
+func (w *WatcherPath) embeddedConfigs(name string) []*WatcherConfig {
+	var wcs []*WatcherConfig
+	for _, embeds := range w.embeds {
+		if embeds.Match(name) {
+			wcs = append(wcs, embeds.wcs...)
+		}
+	}
+	return wcs
+}
@@ -0,0 +1,199 @@
+package fresher
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+// EnvFiles is the paths of the dotenv files loaded every time the binary is started.
+type EnvFiles []string
+
+func (e *EnvFiles) UnmarshalYAML(b []byte) error {
+	var v interface{}
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	if name, ok := v.(string); ok {
+		*e = EnvFiles{name}
+		return nil
+	}
+	var names []string
+	if err := yaml.Unmarshal(b, &names); err != nil {
+		return err
+	}
+	*e = names
+	return nil
+}
+
+// Load reads the files in order. ${VAR} refers to the variables defined above or in the environment of fresher.
+func (e EnvFiles) Load() ([]string, error) {
+	vars := map[string]string{}
+	var environ []string
+	for _, name := range e {
+		parsed, err := parseEnvFile(name, vars)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s: %v", name, err)
+		}
+		environ = append(environ, parsed...)
+	}
+	return environ, nil
+}
+
+func parseEnvFile(name string, vars map[string]string) ([]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	lookup := func(key string) string {
+		if v, exists := vars[key]; exists {
+			return v
+		}
+		return os.Getenv(key)
+	}
+	var (
+		environ []string
+		lineNum int
+	)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+		idx := strings.Index(line, "=")
+		if idx <= 0 {
+			return nil, fmt.Errorf("line %d: invalid line %q", lineNum, line)
+		}
+		key := strings.TrimSpace(line[:idx])
+		raw := strings.TrimSpace(line[idx+1:])
+		// quoted values can continue to the following lines.
+		for isUnclosedQuote(raw) && scanner.Scan() {
+			lineNum++
+			raw += "\n" + scanner.Text()
+		}
+		value, err := parseEnvValue(raw, lookup)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+		vars[key] = value
+		environ = append(environ, fmt.Sprintf("%s=%s", key, value))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return environ, nil
+}
+
+func isUnclosedQuote(raw string) bool {
+	if raw == "" || (raw[0] != '"' && raw[0] != '\'') {
+		return false
+	}
+	_, _, err := splitQuoted(raw)
+	return err != nil
+}
+
+// splitQuoted returns the content between the quotes at the head of raw and the rest after the closing quote.
+func splitQuoted(raw string) (string, string, error) {
+	quote := raw[0]
+	for idx := 1; idx < len(raw); idx++ {
+		switch {
+		case quote == '"' && raw[idx] == '\\':
+			idx++
+		case raw[idx] == quote:
+			return raw[1:idx], raw[idx+1:], nil
+		}
+	}
+	return "", "", fmt.Errorf("unclosed quote in %s", raw)
+}
+
+// parseEnvValue unquotes the value. single-quoted values are used as is,
+// double-quoted values support escapes and ${VAR}, and unquoted values support ${VAR} and trailing # comments.
+func parseEnvValue(raw string, lookup func(string) string) (string, error) {
+	if raw == "" {
+		return "", nil
+	}
+	switch raw[0] {
+	case '\'':
+		value, rest, err := splitQuoted(raw)
+		if err != nil {
+			return "", err
+		}
+		if err := checkEnvRest(rest); err != nil {
+			return "", err
+		}
+		return value, nil
+	case '"':
+		value, rest, err := splitQuoted(raw)
+		if err != nil {
+			return "", err
+		}
+		if err := checkEnvRest(rest); err != nil {
+			return "", err
+		}
+		return expandEnvValue(value, true, lookup), nil
+	}
+	if idx := strings.Index(raw, " #"); idx >= 0 {
+		raw = strings.TrimSpace(raw[:idx])
+	}
+	return expandEnvValue(raw, false, lookup), nil
+}
+
+func checkEnvRest(rest string) error {
+	rest = strings.TrimSpace(rest)
+	if rest == "" || strings.HasPrefix(rest, "#") {
+		return nil
+	}
+	return fmt.Errorf("unexpected characters after quoted value: %s", rest)
+}
+
+// expandEnvValue replaces ${VAR} and $VAR with lookup, and also handles the backslash escapes if escape is true.
+func expandEnvValue(value string, escape bool, lookup func(string) string) string {
+	var b strings.Builder
+	for idx := 0; idx < len(value); idx++ {
+		c := value[idx]
+		switch {
+		case escape && c == '\\' && idx+1 < len(value):
+			idx++
+			switch value[idx] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			default:
+				b.WriteByte(value[idx])
+			}
+		case c == '$' && idx+1 < len(value) && value[idx+1] == '{':
+			end := strings.IndexByte(value[idx:], '}')
+			if end < 0 {
+				b.WriteString(value[idx:])
+				return b.String()
+			}
+			b.WriteString(lookup(value[idx+2 : idx+end]))
+			idx += end
+		case c == '$' && idx+1 < len(value) && isEnvNameChar(value[idx+1]):
+			end := idx + 1
+			for end < len(value) && isEnvNameChar(value[end]) {
+				end++
+			}
+			b.WriteString(lookup(value[idx+1 : end]))
+			idx = end - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func isEnvNameChar(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
@@ -0,0 +1,58 @@
+package fresher
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseEnvFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fresher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tests := []struct {
+		content string
+		want    []string
+		wantErr bool
+	}{
+		{content: "# comment\n\nA=1\n  # indented comment\n", want: []string{"A=1"}},
+		{content: "export A=1\n", want: []string{"A=1"}},
+		{content: "A = 1\n", want: []string{"A=1"}},
+		{content: "A=\n", want: []string{"A="}},
+		{content: "A=1 # comment\n", want: []string{"A=1"}},
+		{content: "A=a#b\n", want: []string{"A=a#b"}},
+		{content: "B=b\nA=${B}-$B\n", want: []string{"B=b", "A=b-b"}},
+		{content: "A=${FRESHER_TEST_UNSET_VAR}\n", want: []string{"A="}},
+		{content: "B=b\nA='x ${B} \\n' # comment\n", want: []string{"B=b", `A=x ${B} \n`}},
+		{content: "B=b\nA=\"x\\t${B} \\\"q\\\"\"\n", want: []string{"B=b", "A=x\tb \"q\""}},
+		{content: "A=\"a\nb\"\nC=c\n", want: []string{"A=a\nb", "C=c"}},
+		{content: "NOEQ\n", wantErr: true},
+		{content: "=v\n", wantErr: true},
+		{content: "A=\"x\n", wantErr: true},
+		{content: "A='x' y\n", wantErr: true},
+	}
+	for idx, tt := range tests {
+		name := filepath.Join(dir, ".env")
+		if err := ioutil.WriteFile(name, []byte(tt.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := parseEnvFile(name, map[string]string{})
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%d: parseEnvFile(%q) = %q, want error", idx, tt.content, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: parseEnvFile(%q): %v", idx, tt.content, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%d: parseEnvFile(%q) = %q, want %q", idx, tt.content, got, tt.want)
+		}
+	}
+}
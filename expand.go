@@ -0,0 +1,134 @@
+package fresher
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// envExpander is implemented by the types whose fields don't correspond to the yaml keys.
+type envExpander interface {
+	expandEnv(path string) error
+}
+
+// expandEnv replaces ${VAR} and ${VAR:-default} with the environment variables. $${ is replaced with ${.
+func expandEnv(s string) (string, error) {
+	var b strings.Builder
+	for {
+		idx := strings.Index(s, "${")
+		if idx < 0 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+		if idx > 0 && s[idx-1] == '$' {
+			b.WriteString(s[:idx-1])
+			b.WriteString("${")
+			s = s[idx+2:]
+			continue
+		}
+		end := strings.Index(s[idx:], "}")
+		if end < 0 {
+			return "", fmt.Errorf("unclosed variable in %q", s)
+		}
+		b.WriteString(s[:idx])
+		value, err := lookupEnv(s[idx+2 : idx+end])
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(value)
+		s = s[idx+end+1:]
+	}
+}
+
+func lookupEnv(expr string) (string, error) {
+	if idx := strings.Index(expr, ":-"); idx >= 0 {
+		if value := os.Getenv(expr[:idx]); value != "" {
+			return value, nil
+		}
+		return expr[idx+2:], nil
+	}
+	value, exists := os.LookupEnv(expr)
+	if !exists {
+		return "", fmt.Errorf("environment variable %s is not set", expr)
+	}
+	return value, nil
+}
+
+func fieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return fmt.Sprintf("%s.%s", path, name)
+}
+
+func yamlFieldName(f reflect.StructField) string {
+	name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+	if name == "" {
+		return strings.ToLower(f.Name)
+	}
+	return name
+}
+
+// expandEnvFields expands the environment variables in all the string fields reachable from v.
+// path is the yaml keys to v, which is used for the error.
+func expandEnvFields(v reflect.Value, path string) error {
+	if v.Kind() != reflect.Ptr && v.CanAddr() {
+		if expander, ok := v.Addr().Interface().(envExpander); ok {
+			return expander.expandEnv(path)
+		}
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		if expander, ok := v.Interface().(envExpander); ok {
+			return expander.expandEnv(path)
+		}
+		return expandEnvFields(v.Elem(), path)
+	case reflect.Struct:
+		t := v.Type()
+		for idx := 0; idx < t.NumField(); idx++ {
+			f := t.Field(idx)
+			if f.PkgPath != "" || f.Tag.Get("yaml") == "-" {
+				continue
+			}
+			if err := expandEnvFields(v.Field(idx), fieldPath(path, yamlFieldName(f))); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		for idx := 0; idx < v.Len(); idx++ {
+			if err := expandEnvFields(v.Index(idx), fmt.Sprintf("%s[%d]", path, idx)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			value := v.MapIndex(key)
+			keyPath := fieldPath(path, fmt.Sprint(key.Interface()))
+			if value.Kind() != reflect.String {
+				if err := expandEnvFields(value, keyPath); err != nil {
+					return err
+				}
+				continue
+			}
+			expanded, err := expandEnv(value.String())
+			if err != nil {
+				return fmt.Errorf("%s: %v", keyPath, err)
+			}
+			v.SetMapIndex(key, reflect.ValueOf(expanded).Convert(value.Type()))
+		}
+	case reflect.String:
+		if !v.CanSet() {
+			return nil
+		}
+		expanded, err := expandEnv(v.String())
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		v.SetString(expanded)
+	}
+	return nil
+}
@@ -1,11 +1,12 @@
 package fresher
 
 import (
-	"context"
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
@@ -14,9 +15,19 @@ import (
 type Option struct {
 	build         *BuildConfig
 	configs       []*WatcherConfig
+	actions       Actions
 	globalExclude *GlobalExclude
+	gitIgnore     bool
+	dockerIgnore  bool
+	ignores       IgnoreRules
 	exts          Extensions
 	interval      time.Duration
+	debounce      *Debounce
+	shell         Shell
+	proxy         *ProxyConfig
+	services      []*ServiceConfig
+	onBuildError  []func(*BuildError)
+	configFile    string
 }
 
 func defaultOption() *Option {
@@ -29,24 +40,41 @@ func defaultOption() *Option {
 				Name: ".",
 			},
 		},
-		exts:     Extensions{"go"},
-		interval: time.Second * 3,
+		globalExclude: &GlobalExclude{},
+		exts:          Extensions{"go"},
+		interval:      time.Second * 3,
 	}
 }
 
+type changeEvent struct {
+	fsnotify.Event
+	configs []*WatcherConfig
+}
+
 type Fresher struct {
-	opt     *Option
-	event   chan fsnotify.Event
-	rebuild chan bool
-	timer   *time.Timer
-	mu      *sync.Mutex
+	opt         *Option
+	rewatch     chan *service
+	reload      chan struct{}
+	reloadTimer *time.Timer
+	pending     map[string]*changeEvent
+	changes     ChangeSet
+	timer       *time.Timer
+	// waitingSince is when the first pending file is changed, and quietUntil is the end of the interval of the leading strategy.
+	// quietTimer performs the changes in that interval.
+	waitingSince time.Time
+	quietUntil   time.Time
+	quietTimer   *time.Timer
+	mu           *sync.Mutex
+	services     []*service
 }
 
 func New(fns ...OptionFunc) *Fresher {
 	fr := &Fresher{
 		opt:     defaultOption(),
-		event:   make(chan fsnotify.Event, 1),
-		rebuild: make(chan bool, 1),
+		rewatch: make(chan *service, 1),
+		reload:  make(chan struct{}, 1),
+		pending: map[string]*changeEvent{},
+		changes: ChangeSet{},
 		mu:      new(sync.Mutex),
 	}
 	for _, fn := range fns {
@@ -55,30 +83,101 @@ func New(fns ...OptionFunc) *Fresher {
 	return fr
 }
 
+// serviceConfigs returns the services of the option. ExecTarget and WatchConfigs are the service without name.
+func serviceConfigs(opt *Option) ([]*ServiceConfig, error) {
+	if len(opt.services) == 0 {
+		return []*ServiceConfig{{Build: opt.build, Paths: opt.configs, Proxy: opt.proxy}}, nil
+	}
+	for _, sc := range opt.services {
+		if sc.Build == nil {
+			return nil, fmt.Errorf("build is required for service %s", sc.Name)
+		}
+		if sc.Host != nil {
+			sc.Build.Host = sc.Host
+		}
+		sc.Build.name = sc.Name
+		if len(sc.Paths) == 0 {
+			sc.Paths = []*WatcherConfig{{Name: "."}}
+		}
+	}
+	return opt.services, nil
+}
+
+func (f *Fresher) initServices() error {
+	scs, err := serviceConfigs(f.opt)
+	if err != nil {
+		return err
+	}
+	for _, sc := range scs {
+		f.services = append(f.services, newService(sc.Name, sc.Build, sc.Paths, sc.Proxy, f.opt))
+	}
+	return nil
+}
+
+func (f *Fresher) configs() []*WatcherConfig {
+	var configs []*WatcherConfig
+	for _, s := range f.services {
+		configs = append(configs, s.watcherConfigs()...)
+	}
+	return configs
+}
+
 func (f *Fresher) Watch() error {
 	log.Info("Start Watching......")
+	if err := f.initServices(); err != nil {
+		return err
+	}
+	for _, s := range f.services {
+		if s.build.AutoWatch {
+			s.loadDependencies()
+			s.refreshConfigs()
+		}
+	}
+	configs := f.configs()
+	if err := f.opt.actions.Validate(configs); err != nil {
+		return err
+	}
+	if err := f.opt.debounce.Validate(); err != nil {
+		return err
+	}
+	if err := f.loadIgnores(); err != nil {
+		return err
+	}
+
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return fmt.Errorf("failed to init watcher: %v\n", err)
 	}
 	defer watcher.Close()
 
+	for _, s := range f.services {
+		if err := s.startProxy(); err != nil {
+			return err
+		}
+	}
+
 	done := make(chan struct{})
 	go func() {
-		quit := make(chan os.Signal)
-		signal.Notify(quit, os.Interrupt)
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 		<-quit
-		f.rebuild <- true
+		signal.Stop(quit)
+		f.mu.Lock()
+		services := f.services
+		f.mu.Unlock()
+		for _, s := range services {
+			s.shutdown()
+		}
 		close(quit)
 		close(done)
 	}()
 
-	if err := f.run(); err != nil {
-		log.Error(fmt.Errorf("failed to build: %v\n", err))
+	for _, s := range f.services {
+		s.restart(nil)
 	}
 
-	watcherPath := NewWatcherPath(f.opt.configs, f.opt)
-	for _, path := range f.opt.configs {
+	watcherPath := NewWatcherPath(configs, f.opt)
+	for _, path := range configs {
 		wp, err := path.Walk(watcher, f.opt)
 		if err != nil {
 			return err
@@ -86,14 +185,43 @@ func (f *Fresher) Watch() error {
 		watcherPath.Merge(wp)
 	}
 
+	if f.opt.configFile != "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		if err := watcher.Add(configDir(f.opt.configFile, wd)); err != nil {
+			return err
+		}
+	}
+
 	go f.publish(watcher, watcherPath)
-	go f.subscribe()
 
 	<-done
 
 	return nil
 }
 
+func (f *Fresher) loadIgnores() error {
+	var ignores IgnoreRules
+	if f.opt.gitIgnore {
+		rules, err := LoadGitIgnore(".")
+		if err != nil {
+			return fmt.Errorf("failed to load %s: %v", gitIgnoreFileName, err)
+		}
+		ignores = append(ignores, rules...)
+	}
+	if f.opt.dockerIgnore {
+		rules, err := LoadDockerIgnore(".")
+		if err != nil {
+			return fmt.Errorf("failed to load %s: %v", dockerIgnoreFileName, err)
+		}
+		ignores = append(ignores, rules...)
+	}
+	f.opt.ignores = ignores
+	return nil
+}
+
 func (f *Fresher) publish(watcher *fsnotify.Watcher, watcherPath *WatcherPath) {
 	for {
 		select {
@@ -104,68 +232,155 @@ func (f *Fresher) publish(watcher *fsnotify.Watcher, watcherPath *WatcherPath) {
 			if event.Op&fsnotify.Chmod == fsnotify.Chmod {
 				continue
 			}
+			event.Name = filepath.Clean(event.Name)
+			if f.isConfigFile(event.Name) {
+				f.reserveReload()
+				continue
+			}
+			if _, watched := watcherPath.Configs(event.Name); watched && event.Op&fsnotify.Create == fsnotify.Create {
+				// the file is replaced by renaming another one, which is how some editors save.
+				event.Op = fsnotify.Write
+			}
 			if event.Op&fsnotify.Create == fsnotify.Create {
-				if err := watcherPath.AddIfNeeds(event.Name, watcher); err != nil {
-					if err != skipToAddErr {
-						log.Error(err)
-					}
+				if err := watcherPath.AddIfNeeds(event.Name, watcher); err != nil && err != skipToAddErr {
+					log.Error(err)
 					continue
 				}
 			}
-			if _, exists := watcherPath.watches[event.Name]; !exists {
+			configs, exists := watcherPath.Configs(event.Name)
+			if !exists {
 				continue
 			}
-			f.event <- event
+			if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+				watcherPath.forget(event.Name)
+			}
+			if event.Op&(fsnotify.Create|fsnotify.Write) != 0 {
+				if err := watcherPath.watchEmbeds(event.Name, configs, watcher); err != nil {
+					log.Error(err)
+				}
+			}
+			f.reserve(&changeEvent{
+				Event:   event,
+				configs: append([]*WatcherConfig{}, configs...),
+			})
 		case err, ok := <-watcher.Errors:
 			if !ok {
 				continue
 			}
 			log.Error(err)
+		case <-f.reload:
+			f.reloadConfig(watcher, watcherPath)
+		case s := <-f.rewatch:
+			if err := f.watchService(watcher, watcherPath, s); err != nil {
+				s.log.Error(err)
+			}
 		}
 	}
 }
 
-func (f *Fresher) run() error {
-	log.Building()
-	commands := f.opt.build.Commands()
-	ctx, cancel := context.WithCancel(context.Background())
-	for _, cmd := range commands {
-		if err := cmd.ExecContext(ctx); err != nil {
+// watchService walks the new configs of the service and stops the events for its old ones.
+func (f *Fresher) watchService(watcher *fsnotify.Watcher, watcherPath *WatcherPath, s *service) error {
+	s.log.Info("Update watched packages")
+	s.lifecycle.Lock()
+	configs := s.watcherConfigs()
+	s.lifecycle.Unlock()
+	watcherPath.replace(s, configs)
+	for _, wc := range configs {
+		wp, err := wc.Walk(watcher, f.opt)
+		if err != nil {
 			return err
 		}
+		watcherPath.Merge(wp)
 	}
-	go func() {
-		<-f.rebuild
-		cancel()
-		for _, cmd := range commands {
-			cmd.Kill()
-		}
-	}()
 	return nil
 }
 
-func (f *Fresher) reserve() error {
-	timer := time.AfterFunc(f.opt.interval, func() {
-		f.rebuild <- true
-		if err := f.run(); err != nil {
-			log.Error(err)
-			return
+// reserve merges the changed file into the pending ones performed after the interval.
+// the files only ignored by the configs don't start the interval.
+func (f *Fresher) reserve(event *changeEvent) {
+	ignored := true
+	for _, wc := range event.configs {
+		if wc.actionFor(event.Name) != ActionIgnore {
+			ignored = false
 		}
-	})
+	}
+	if ignored {
+		return
+	}
 	f.mu.Lock()
-	if f.timer != nil {
-		f.timer.Stop()
+	defer f.mu.Unlock()
+	if !f.debounce(time.Now()) {
+		return
 	}
-	f.timer = timer
+	f.pending[event.Name] = event
+	f.changes.add(event.Name, event.Op)
+}
+
+// serviceChanges is the changes of the files watched by a service and the actions for them.
+type serviceChanges struct {
+	actions map[string]struct{}
+	changes ChangeSet
+}
+
+// fire performs the actions only for the services whose watched files are changed.
+func (f *Fresher) fire() {
+	f.mu.Lock()
+	pending := f.pending
+	changes := f.changes
+	f.pending = map[string]*changeEvent{}
+	f.changes = ChangeSet{}
+	services := f.services
+	actions := f.opt.actions
 	f.mu.Unlock()
-	return nil
+
+	byService := map[*service]*serviceChanges{}
+	skipped := ChangeSet{}
+	for _, name := range changes.Paths() {
+		configs := f.actionConfigs(pending[name])
+		if len(configs) == 0 {
+			skipped[name] = changes[name]
+			continue
+		}
+		for _, wc := range configs {
+			sc, exists := byService[wc.service]
+			if !exists {
+				sc = &serviceChanges{actions: map[string]struct{}{}, changes: ChangeSet{}}
+				byService[wc.service] = sc
+			}
+			sc.actions[wc.actionFor(name)] = struct{}{}
+			sc.changes[name] = changes[name]
+		}
+	}
+	if len(skipped) > 0 {
+		log.SkipFiles(skipped)
+	}
+	for _, s := range services {
+		sc, exists := byService[s]
+		if !exists {
+			continue
+		}
+		s.log.UpdateFiles(sc.changes)
+		s.perform(actions.plan(sc.actions), sc.changes)
+		if s.refreshConfigs() {
+			f.rewatch <- s
+		}
+	}
 }
-func (f *Fresher) subscribe() {
-	for {
-		event := <-f.event
-		log.UpdateFile(event.Name)
-		if err := f.reserve(); err != nil {
-			log.Println(err)
+
+// actionConfigs drops the configs whose action is ignore for the changed file,
+// or which rebuild the service whose binary doesn't depend on the changed file.
+func (f *Fresher) actionConfigs(event *changeEvent) []*WatcherConfig {
+	var configs []*WatcherConfig
+	for _, wc := range event.configs {
+		switch wc.actionFor(event.Name) {
+		case ActionIgnore:
+			continue
+		case ActionRebuild:
+			if !wc.service.isDependent(event.Name) {
+				continue
+			}
 		}
+		configs = append(configs, wc)
 	}
+	return configs
 }
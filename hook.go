@@ -0,0 +1,169 @@
+package fresher
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+const (
+	// HookEventStart is the first build of the service.
+	HookEventStart = "start"
+	// HookEventChange is the build or the restart for the changed files.
+	HookEventChange = "change"
+	// HookEventReload is the build for the changes of the config file.
+	HookEventReload = "reload"
+)
+
+// Words is a list rendered separated by spaces in the templates of arg.
+type Words []string
+
+func (w Words) String() string {
+	return strings.Join(w, " ")
+}
+
+// Hook is why the before and after hooks and the commands of the actions run.
+// it is passed to them as the environment variables, and is the data of the templates in their arg.
+type Hook struct {
+	Event       string
+	BuildNumber int
+	// Changes are empty unless Event is HookEventChange.
+	Changes         ChangeSet
+	ChangedFiles    Words
+	ChangedPackages Words
+	ChangesFile     string
+}
+
+func newHook(event string, buildNumber int, changes ChangeSet, changesFile string) *Hook {
+	return &Hook{
+		Event:           event,
+		BuildNumber:     buildNumber,
+		Changes:         changes,
+		ChangedFiles:    changes.Paths(),
+		ChangedPackages: changedPackages(changes),
+		ChangesFile:     changesFile,
+	}
+}
+
+// changedPackages returns the directories of the changed go files as the relative package paths such as ./cmd/app.
+// the removed directories are dropped, since go cannot find them.
+func changedPackages(changes ChangeSet) Words {
+	dirs := map[string]struct{}{}
+	for name := range changes {
+		if filepath.Ext(name) != ".go" {
+			continue
+		}
+		dir := filepath.Dir(name)
+		if _, err := os.Stat(dir); err != nil {
+			continue
+		}
+		if !filepath.IsAbs(dir) && dir != "." {
+			dir = "./" + filepath.ToSlash(dir)
+		}
+		dirs[dir] = struct{}{}
+	}
+	packages := make(Words, 0, len(dirs))
+	for dir := range dirs {
+		packages = append(packages, dir)
+	}
+	sort.Strings(packages)
+	return packages
+}
+
+func (h *Hook) environ() []string {
+	environ := []string{
+		fmt.Sprintf("FRESHER_EVENT=%s", h.Event),
+		fmt.Sprintf("FRESHER_BUILD_NUMBER=%d", h.BuildNumber),
+		fmt.Sprintf("FRESHER_CHANGED_FILES=%s", h.ChangedFiles),
+		fmt.Sprintf("FRESHER_CHANGED_PACKAGES=%s", h.ChangedPackages),
+	}
+	if h.ChangesFile != "" {
+		environ = append(environ, fmt.Sprintf("%s=%s", ChangesFileEnv, h.ChangesFile))
+	}
+	return environ
+}
+
+// matches reports whether any changed file matches the pattern of when. it is always true unless the files are changed.
+func (h *Hook) matches(when string) (bool, error) {
+	if when == "" || h.Event != HookEventChange {
+		return true, nil
+	}
+	for name := range h.Changes {
+		ok, err := matchPattern(when, name)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// quoted returns the hook whose file names are quoted for the command line of the shell.
+func (h *Hook) quoted() *Hook {
+	q := *h
+	q.ChangedFiles = quoteWords(h.ChangedFiles)
+	q.ChangedPackages = quoteWords(h.ChangedPackages)
+	if h.ChangesFile != "" {
+		q.ChangesFile = shellQuote(h.ChangesFile)
+	}
+	return &q
+}
+
+func quoteWords(words Words) Words {
+	quoted := make(Words, 0, len(words))
+	for _, word := range words {
+		quoted = append(quoted, shellQuote(word))
+	}
+	return quoted
+}
+
+// listPlaceholder is an arg consisting only of a field, which is expanded to an arg per item if the field is Words.
+var listPlaceholder = regexp.MustCompile(`^{{\s*\.(\w+)\s*}}$`)
+
+// expandArg renders the templates in the args with the hook.
+func (h *Hook) expandArg(args []string) ([]string, error) {
+	expanded := make([]string, 0, len(args))
+	for _, arg := range args {
+		if !strings.Contains(arg, "{{") {
+			expanded = append(expanded, arg)
+			continue
+		}
+		if m := listPlaceholder.FindStringSubmatch(arg); m != nil {
+			if field := reflect.ValueOf(h).Elem().FieldByName(m[1]); field.IsValid() {
+				if words, ok := field.Interface().(Words); ok {
+					expanded = append(expanded, words...)
+					continue
+				}
+			}
+		}
+		tmpl, err := template.New("arg").Option("missingkey=error").Parse(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid arg %s: %v", arg, err)
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, h); err != nil {
+			return nil, fmt.Errorf("invalid arg %s: %v", arg, err)
+		}
+		expanded = append(expanded, b.String())
+	}
+	return expanded, nil
+}
+
+type hookContextKey struct{}
+
+func contextWithHook(ctx context.Context, h *Hook) context.Context {
+	return context.WithValue(ctx, hookContextKey{}, h)
+}
+
+func hookFromContext(ctx context.Context) *Hook {
+	h, _ := ctx.Value(hookContextKey{}).(*Hook)
+	return h
+}
@@ -1,6 +1,8 @@
 package fresher
 
 import (
+	"fmt"
+
 	"github.com/goccy/go-yaml"
 )
 
@@ -42,6 +44,15 @@ func (h *Host) UnmarshalYAML(b []byte) error {
 	return nil
 }
 
+func (h *Host) expandEnv(path string) error {
+	location, err := expandEnv(h.LocationName)
+	if err != nil {
+		return fmt.Errorf("%s.%s: %v", path, h.Type, err)
+	}
+	h.LocationName = location
+	return nil
+}
+
 func toHostType(host string) hostType {
 	switch host {
 	case hostNameDocker:
@@ -50,27 +61,36 @@ func toHostType(host string) hostType {
 	return HostTypeLocal
 }
 
+// RunCommand returns the command running the binary of path on the host.
 func (h *Host) RunCommand(path string) Executor {
+	return h.Command(&Command{
+		Name:    path,
+		IsAsync: true,
+	})
+}
+
+// Command returns the command running cmd on the host. environ is passed to the container with -e for docker.
+func (h *Host) Command(cmd *Command, environ ...string) Executor {
 	switch h.Type {
 	case HostTypeDocker:
+		arg := []string{"exec"}
+		for _, env := range environ {
+			arg = append(arg, "-e", env)
+		}
 		return &DockerCommand{
 			Command: &Command{
-				Name: "docker",
-				Arg: []string{
-					"exec",
-					h.LocationName,
-					path,
-				},
-				IsAsync: true,
+				Name:        "docker",
+				Arg:         append(append(arg, h.LocationName, cmd.Name), cmd.Arg...),
+				IsAsync:     true,
+				StopSignal:  cmd.StopSignal,
+				StopTimeout: cmd.StopTimeout,
+				Restart:     cmd.Restart,
 			},
-			binPath: path,
+			binPath: cmd.Name,
 			host:    h,
 		}
 	default:
-		return &Command{
-			Name:    path,
-			IsAsync: true,
-		}
+		return cmd
 	}
 
 }
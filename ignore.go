@@ -0,0 +1,168 @@
+package fresher
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	gitIgnoreFileName    = ".gitignore"
+	dockerIgnoreFileName = ".dockerignore"
+)
+
+type ignoreRule struct {
+	base     string
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+func (r *ignoreRule) match(name string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.base != "" {
+		if !strings.HasPrefix(name, r.base+"/") {
+			return false
+		}
+		name = strings.TrimPrefix(name, r.base+"/")
+	}
+	if !r.anchored {
+		ok, _ := path.Match(r.pattern, path.Base(name))
+		return ok
+	}
+	ok, _ := matchSegments(splitPath(r.pattern), splitPath(name), false)
+	return ok
+}
+
+// IgnoreRules is a list of rules read from .gitignore or .dockerignore. the last matched rule wins like git.
+type IgnoreRules []*ignoreRule
+
+// IsIgnored reports whether path relative to the current directory is ignored.
+// files under an ignored directory are always ignored, even if a negation rule matches them.
+func (rules IgnoreRules) IsIgnored(name string, isDir bool) bool {
+	if len(rules) == 0 {
+		return false
+	}
+	parts := splitPath(filepath.ToSlash(name))
+	for i := 1; i < len(parts); i++ {
+		if rules.match(strings.Join(parts[:i], "/"), true) {
+			return true
+		}
+	}
+	return rules.match(strings.Join(parts, "/"), isDir)
+}
+
+func (rules IgnoreRules) match(name string, isDir bool) bool {
+	var ignored bool
+	for _, rule := range rules {
+		if rule.match(name, isDir) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+func parseIgnoreLine(line string) (*ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil, false
+	}
+	rule := &ignoreRule{}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\#`) || strings.HasPrefix(line, `\!`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return nil, false
+	}
+	rule.pattern = line
+	return rule, true
+}
+
+func readIgnoreFile(filename string, parse func(string) (*ignoreRule, bool)) (IgnoreRules, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	var rules IgnoreRules
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if rule, ok := parse(scanner.Text()); ok {
+			rules = append(rules, rule)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// LoadGitIgnore reads .gitignore in dir and its subdirectories. the rules in nested files are relative to their directory.
+func LoadGitIgnore(dir string) (IgnoreRules, error) {
+	rules := IgnoreRules{
+		{pattern: ".git", dirOnly: true},
+	}
+	if err := filepath.Walk(dir, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, name)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !info.IsDir() {
+			return nil
+		}
+		if rel != "." && rules.IsIgnored(rel, true) {
+			return filepath.SkipDir
+		}
+		nested, err := readIgnoreFile(filepath.Join(name, gitIgnoreFileName), parseIgnoreLine)
+		if err != nil {
+			return err
+		}
+		for _, rule := range nested {
+			if rel != "." {
+				rule.base = rel
+			}
+		}
+		rules = append(rules, nested...)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// LoadDockerIgnore reads .dockerignore in dir. all patterns are relative to dir.
+func LoadDockerIgnore(dir string) (IgnoreRules, error) {
+	return readIgnoreFile(filepath.Join(dir, dockerIgnoreFileName), func(line string) (*ignoreRule, bool) {
+		rule, ok := parseIgnoreLine(line)
+		if !ok {
+			return nil, false
+		}
+		rule.pattern = strings.TrimPrefix(path.Clean("/"+rule.pattern), "/")
+		rule.anchored = true
+		rule.dirOnly = false
+		return rule, true
+	})
+}
@@ -0,0 +1,118 @@
+package fresher
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeIgnoreFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "fresher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestGitIgnore(t *testing.T) {
+	dir := writeIgnoreFiles(t, map[string]string{
+		".gitignore": "# comment\n" +
+			"*.log\n" +
+			"!keep.log\n" +
+			"build/\n" +
+			"/root.txt\n" +
+			"docs/*.md\n" +
+			"**/gen/*.go\n" +
+			"vendor/**\n",
+		"sub/.gitignore": "*.tmp\n/local.txt\n",
+	})
+	defer os.RemoveAll(dir)
+	rules, err := LoadGitIgnore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		isDir bool
+		want  bool
+	}{
+		{name: ".git/config", want: true},
+		{name: "a.log", want: true},
+		{name: "sub/a.log", want: true},
+		{name: "keep.log", want: false},
+		{name: "sub/keep.log", want: false},
+		{name: "build", isDir: true, want: true},
+		{name: "build", want: false},
+		{name: "sub/build/x.go", want: true},
+		{name: "build/keep.log", want: true},
+		{name: "root.txt", want: true},
+		{name: "sub/root.txt", want: false},
+		{name: "docs/a.md", want: true},
+		{name: "sub/docs/a.md", want: false},
+		{name: "docs/sub/a.md", want: false},
+		{name: "gen/x.go", want: true},
+		{name: "a/b/gen/x.go", want: true},
+		{name: "gen/sub/x.go", want: false},
+		{name: "vendor/a/b.go", want: true},
+		{name: "sub/x.tmp", want: true},
+		{name: "x.tmp", want: false},
+		{name: "sub/local.txt", want: true},
+		{name: "sub/deeper/local.txt", want: false},
+		{name: "main.go", want: false},
+	}
+	for _, tt := range tests {
+		if got := rules.IsIgnored(tt.name, tt.isDir); got != tt.want {
+			t.Errorf("IsIgnored(%q, %v) = %v, want %v", tt.name, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestDockerIgnore(t *testing.T) {
+	dir := writeIgnoreFiles(t, map[string]string{
+		".dockerignore": "# comment\n" +
+			"*.md\n" +
+			"!README.md\n" +
+			"tmp\n" +
+			"/out/**\n" +
+			"**/*.bak\n" +
+			"cache/\n",
+	})
+	defer os.RemoveAll(dir)
+	rules, err := LoadDockerIgnore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		isDir bool
+		want  bool
+	}{
+		{name: "a.md", want: true},
+		{name: "docs/a.md", want: false},
+		{name: "README.md", want: false},
+		{name: "tmp", want: true},
+		{name: "tmp/x.go", want: true},
+		{name: "sub/tmp", want: false},
+		{name: "out/x/y.go", want: true},
+		{name: "c.bak", want: true},
+		{name: "a/b/c.bak", want: true},
+		{name: "cache", want: true},
+		{name: "cache/x", want: true},
+		{name: "main.go", want: false},
+	}
+	for _, tt := range tests {
+		if got := rules.IsIgnored(tt.name, tt.isDir); got != tt.want {
+			t.Errorf("IsIgnored(%q, %v) = %v, want %v", tt.name, tt.isDir, got, tt.want)
+		}
+	}
+}
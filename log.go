@@ -1,6 +1,7 @@
 package fresher
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/sirupsen/logrus"
@@ -8,6 +9,7 @@ import (
 
 type Log struct {
 	*logrus.Logger
+	prefix string
 }
 
 var log *Log
@@ -23,19 +25,52 @@ const (
 )
 
 func (l *Log) WatchFile(path string) {
-	l.Info(l.msg(magenta, fmt.Sprintf("Watching file [%s]", path)))
+	l.Logger.Info(l.msg(magenta, fmt.Sprintf("Watching file [%s]", path)))
 }
 
-func (l *Log) UpdateFile(path string) {
-	l.Info(l.msg(green, fmt.Sprintf("Rebuild to updated watched file [%s]", path)))
+// UpdateFiles reports the changes performed together in a line.
+func (l *Log) UpdateFiles(changes ChangeSet) {
+	if len(changes) == 1 {
+		for name, kind := range changes {
+			l.Logger.Info(l.msg(green, fmt.Sprintf("Updated watched file [%s] (%s)", name, kind)))
+		}
+		return
+	}
+	l.Logger.Info(l.msg(green, fmt.Sprintf("Updated %d watched files (%s)", len(changes), changes.Summary())))
+}
+
+// SkipFiles reports the changes which don't need any action, such as the files the binary doesn't depend on.
+func (l *Log) SkipFiles(changes ChangeSet) {
+	if len(changes) == 1 {
+		for name := range changes {
+			l.Logger.Info(l.msg(blue, fmt.Sprintf("Skip changed file [%s]", name)))
+		}
+		return
+	}
+	l.Logger.Info(l.msg(blue, fmt.Sprintf("Skip %d changed files (%s)", len(changes), changes.Summary())))
 }
 
 func (l *Log) IgnoreFile(path string) {
-	l.Infof(l.msg(yellow, fmt.Sprintf("Ignore file [%s]", path)))
+	l.Logger.Info(l.msg(yellow, fmt.Sprintf("Ignore file [%s]", path)))
+}
+
+func (l *Log) RunAction(name string) {
+	l.Logger.Info(l.msg(yellow, fmt.Sprintf("Run action [%s]", name)))
 }
 
 func (l *Log) Building() {
-	l.Info(l.msg(yellow, "Building..."))
+	l.Logger.Info(l.msg(yellow, "Building..."))
+}
+
+func (l *Log) Ready() {
+	l.Logger.Info(l.msg(green, "Ready"))
+}
+
+func (l *Log) BuildFailed(err *BuildError) {
+	l.Logger.Info(l.msg(red, err.Error()))
+	for _, d := range err.Diagnostics {
+		l.Logger.Info(l.msg(cyan, fmt.Sprintf("  %s %s", d.Position(), d.Message)))
+	}
 }
 
 func (l *Log) Info(msg string) {
@@ -43,13 +78,37 @@ func (l *Log) Info(msg string) {
 }
 
 func (l *Log) Error(v interface{}) {
-	l.Info(l.msg(red, fmt.Sprint(v)))
+	l.Logger.Info(l.msg(red, fmt.Sprint(v)))
 }
 
 func (l *Log) msg(code int, msg string) string {
+	if l.prefix != "" {
+		msg = fmt.Sprintf("[%s] %s", l.prefix, msg)
+	}
 	return fmt.Sprintf("\033[%dm%s\033[0m", code, fmt.Sprintf("%s: %s", "Fresher Watch", msg))
 }
 
+// WithPrefix returns the logger which prefixes messages, e.g. with the service name.
+func (l *Log) WithPrefix(prefix string) *Log {
+	return &Log{
+		Logger: l.Logger,
+		prefix: prefix,
+	}
+}
+
+type logContextKey struct{}
+
+func contextWithLog(ctx context.Context, l *Log) context.Context {
+	return context.WithValue(ctx, logContextKey{}, l)
+}
+
+func logFromContext(ctx context.Context) *Log {
+	if l, ok := ctx.Value(logContextKey{}).(*Log); ok {
+		return l
+	}
+	return log
+}
+
 func init() {
 	log = &Log{
 		Logger: logrus.New(),
@@ -0,0 +1,140 @@
+package fresher
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// matchPattern reports whether name matches pattern.
+// A pattern without a slash is matched against the base name of name as before, so `const.go` matches in every directory.
+// Otherwise, it is matched against the whole name separated by slashes, and `**` matches zero or more directories.
+func matchPattern(pattern, name string) (bool, error) {
+	pattern = filepath.ToSlash(pattern)
+	name = filepath.ToSlash(name)
+	if !strings.Contains(pattern, "/") {
+		return path.Match(pattern, path.Base(name))
+	}
+	return matchSegments(splitPath(pattern), splitPath(name), false)
+}
+
+// matchDirPattern reports whether files under the directory dir can match pattern.
+// A pattern without a slash can match the files in every directory.
+func matchDirPattern(pattern, dir string) (bool, error) {
+	pattern = filepath.ToSlash(pattern)
+	dir = filepath.ToSlash(dir)
+	if !strings.Contains(pattern, "/") {
+		return true, nil
+	}
+	return matchSegments(splitPath(pattern), splitPath(dir), true)
+}
+
+// validatePattern checks the syntax of each segment of the pattern.
+// it is scanned here, since path.Match of the older go may return before reading the whole pattern.
+func validatePattern(pattern string) error {
+	for _, segment := range strings.Split(filepath.ToSlash(pattern), "/") {
+		if segment == "**" {
+			continue
+		}
+		if err := validateSegment(segment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateSegment(segment string) error {
+	for idx := 0; idx < len(segment); idx++ {
+		switch segment[idx] {
+		case '\\':
+			idx++
+			if idx >= len(segment) {
+				return path.ErrBadPattern
+			}
+		case '[':
+			idx++
+			if idx < len(segment) && segment[idx] == '^' {
+				idx++
+			}
+			n, err := scanClass(segment[idx:])
+			if err != nil {
+				return err
+			}
+			idx += n
+		}
+	}
+	return nil
+}
+
+// scanClass returns the index of the ] closing the character class.
+func scanClass(class string) (int, error) {
+	for idx, ranges := 0, 0; idx < len(class); ranges++ {
+		if class[idx] == ']' && ranges > 0 {
+			return idx, nil
+		}
+		n, err := classChar(class[idx:])
+		if err != nil {
+			return 0, err
+		}
+		idx += n
+		if idx < len(class) && class[idx] == '-' {
+			n, err := classChar(class[idx+1:])
+			if err != nil {
+				return 0, err
+			}
+			idx += 1 + n
+		}
+	}
+	return 0, path.ErrBadPattern
+}
+
+// classChar returns the length of a character of the class, which may be escaped by a backslash.
+func classChar(s string) (int, error) {
+	if s == "" || s[0] == '-' || s[0] == ']' {
+		return 0, path.ErrBadPattern
+	}
+	n := 0
+	if s[0] == '\\' {
+		n = 1
+		if len(s) == 1 {
+			return 0, path.ErrBadPattern
+		}
+	}
+	r, size := utf8.DecodeRuneInString(s[n:])
+	if r == utf8.RuneError && size == 1 {
+		return 0, path.ErrBadPattern
+	}
+	return n + size, nil
+}
+
+func splitPath(name string) []string {
+	name = strings.Trim(path.Clean(name), "/")
+	if name == "." || name == "" {
+		return nil
+	}
+	return strings.Split(name, "/")
+}
+
+func matchSegments(patterns, names []string, prefix bool) (bool, error) {
+	for len(patterns) > 0 {
+		if patterns[0] == "**" {
+			for i := 0; i <= len(names); i++ {
+				ok, err := matchSegments(patterns[1:], names[i:], prefix)
+				if err != nil || ok {
+					return ok, err
+				}
+			}
+			return false, nil
+		}
+		if len(names) == 0 {
+			return prefix, nil
+		}
+		ok, err := path.Match(patterns[0], names[0])
+		if err != nil || !ok {
+			return false, err
+		}
+		patterns, names = patterns[1:], names[1:]
+	}
+	return len(names) == 0, nil
+}
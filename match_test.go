@@ -0,0 +1,89 @@
+package fresher
+
+import "testing"
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{pattern: "const.go", name: "const.go", want: true},
+		{pattern: "const.go", name: "a/b/const.go", want: true},
+		{pattern: "*.go", name: "a/b/const.go", want: true},
+		{pattern: "a/const.go", name: "a/b/const.go", want: false},
+		{pattern: "internal/gen/**", name: "internal/gen", want: true},
+		{pattern: "internal/gen/**", name: "internal/gen/x.go", want: true},
+		{pattern: "internal/gen/**", name: "internal/genx/x.go", want: false},
+		{pattern: "pkg/**/handler_*.go", name: "pkg/handler_user.go", want: true},
+		{pattern: "pkg/**/handler_*.go", name: "pkg/a/b/handler_user.go", want: true},
+		{pattern: "pkg/**/handler_*.go", name: "pkg/a/b/user.go", want: false},
+	}
+	for _, tt := range tests {
+		got, err := matchPattern(tt.pattern, tt.name)
+		if err != nil {
+			t.Fatalf("matchPattern(%q, %q): %v", tt.pattern, tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("matchPattern(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMatchDirPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		dir     string
+		want    bool
+	}{
+		{pattern: "*.go", dir: "pkg", want: true},
+		{pattern: "handler_*.go", dir: "pkg/a", want: true},
+		{pattern: "pkg/**/handler_*.go", dir: "pkg", want: true},
+		{pattern: "pkg/**/handler_*.go", dir: "pkg/a/b", want: true},
+		{pattern: "pkg/**/handler_*.go", dir: "cmd", want: false},
+		{pattern: "internal/gen/*.go", dir: "internal", want: true},
+		{pattern: "internal/gen/*.go", dir: "internal/gen", want: true},
+		{pattern: "internal/gen/*.go", dir: "internal/genx", want: false},
+		{pattern: "internal/gen/*.go", dir: "internal/gen/sub", want: false},
+	}
+	for _, tt := range tests {
+		got, err := matchDirPattern(tt.pattern, tt.dir)
+		if err != nil {
+			t.Fatalf("matchDirPattern(%q, %q): %v", tt.pattern, tt.dir, err)
+		}
+		if got != tt.want {
+			t.Errorf("matchDirPattern(%q, %q) = %v, want %v", tt.pattern, tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestValidatePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		wantErr bool
+	}{
+		{pattern: "*.go"},
+		{pattern: "pkg/**/handler_*.go"},
+		{pattern: "[a-z]*.go"},
+		{pattern: "[^_]*.go"},
+		{pattern: `\*.go`},
+		{pattern: `[\]]`},
+		{pattern: "x[", wantErr: true},
+		{pattern: "x[a", wantErr: true},
+		{pattern: "*.go[", wantErr: true},
+		{pattern: `x\`, wantErr: true},
+		{pattern: `x[\`, wantErr: true},
+		{pattern: "x[]", wantErr: true},
+		{pattern: "x[-a]", wantErr: true},
+		{pattern: "pkg/x[/a.go", wantErr: true},
+	}
+	for _, tt := range tests {
+		err := validatePattern(tt.pattern)
+		if tt.wantErr && err == nil {
+			t.Errorf("validatePattern(%q) = nil, want error", tt.pattern)
+		}
+		if !tt.wantErr && err != nil {
+			t.Errorf("validatePattern(%q): %v", tt.pattern, err)
+		}
+	}
+}
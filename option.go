@@ -18,12 +18,30 @@ func WatchConfigs(configs []*WatcherConfig) OptionFunc {
 	}
 }
 
+func WatchActions(actions Actions) OptionFunc {
+	return func(f *Fresher) {
+		f.opt.actions = actions
+	}
+}
+
 func GlobalExcludePath(global *GlobalExclude) OptionFunc {
 	return func(f *Fresher) {
 		f.opt.globalExclude = global
 	}
 }
 
+func GitIgnore(enabled bool) OptionFunc {
+	return func(f *Fresher) {
+		f.opt.gitIgnore = enabled
+	}
+}
+
+func DockerIgnore(enabled bool) OptionFunc {
+	return func(f *Fresher) {
+		f.opt.dockerIgnore = enabled
+	}
+}
+
 func ExtensionPaths(exts Extensions) OptionFunc {
 	return func(f *Fresher) {
 		f.opt.exts = exts
@@ -35,3 +53,42 @@ func WatchInterval(interval time.Duration) OptionFunc {
 		f.opt.interval = interval
 	}
 }
+
+func WatchDebounce(d *Debounce) OptionFunc {
+	return func(f *Fresher) {
+		f.opt.debounce = d
+	}
+}
+
+// CommandShell sets the interpreter of the commands with shell, `sh -c` by default.
+func CommandShell(shell Shell) OptionFunc {
+	return func(f *Fresher) {
+		f.opt.shell = shell
+	}
+}
+
+func ReverseProxy(pc *ProxyConfig) OptionFunc {
+	return func(f *Fresher) {
+		f.opt.proxy = pc
+	}
+}
+
+// Services builds and runs several applications with one watcher instead of ExecTarget and WatchConfigs.
+func Services(services []*ServiceConfig) OptionFunc {
+	return func(f *Fresher) {
+		f.opt.services = services
+	}
+}
+
+func BuildErrorHandler(fn func(*BuildError)) OptionFunc {
+	return func(f *Fresher) {
+		f.opt.onBuildError = append(f.opt.onBuildError, fn)
+	}
+}
+
+// ConfigFile reloads the config file when it is changed while watching.
+func ConfigFile(filename string) OptionFunc {
+	return func(f *Fresher) {
+		f.opt.configFile = filename
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/fsnotify/fsnotify"
@@ -23,9 +24,10 @@ func (e Extensions) IsIncludeSameExt(fileName string) bool {
 
 type GlobalExclude []string
 
+// IsExclude matches path relative to the current directory with the patterns.
 func (g GlobalExclude) IsExclude(path string) (bool, error) {
 	for _, d := range g {
-		ok, err := filepath.Match(d, path)
+		ok, err := matchPattern(d, path)
 		if err != nil {
 			return false, err
 		}
@@ -37,16 +39,28 @@ func (g GlobalExclude) IsExclude(path string) (bool, error) {
 }
 
 type WatcherConfig struct {
-	Name     string   `yaml:"name"`
-	Excludes []string `yaml:"exclude"`
-	Includes []string `yaml:"include"`
+	Name       string        `yaml:"name"`
+	Excludes   []string      `yaml:"exclude"`
+	Includes   []string      `yaml:"include"`
+	Action     string        `yaml:"action"`
+	Extensions Extensions    `yaml:"extension"`
+	Rules      []*ActionRule `yaml:"on"`
+	service    *service
+
+	// flat and files are set to the configs generated from the dependencies of the build target.
+	// flat watches the files directly in the directory, and files are watched regardless of the extensions.
+	flat  bool
+	files map[string]struct{}
 }
 
 func (r *WatcherConfig) UnmarshalYAML(b []byte) error {
 	s := struct {
-		Name     string   `yaml:"name"`
-		Excludes []string `yaml:"exclude"`
-		Includes []string `yaml:"include"`
+		Name       string        `yaml:"name"`
+		Excludes   []string      `yaml:"exclude"`
+		Includes   []string      `yaml:"include"`
+		Action     string        `yaml:"action"`
+		Extensions Extensions    `yaml:"extension"`
+		Rules      []*ActionRule `yaml:"on"`
 	}{}
 	if err := yaml.Unmarshal(b, &s); err != nil {
 		var name string
@@ -59,12 +73,37 @@ func (r *WatcherConfig) UnmarshalYAML(b []byte) error {
 	r.Name = s.Name
 	r.Excludes = s.Excludes
 	r.Includes = s.Includes
+	r.Action = s.Action
+	r.Extensions = s.Extensions
+	r.Rules = s.Rules
 	return nil
 }
 
+func (r *WatcherConfig) action() string {
+	if r.Action == "" {
+		return ActionRebuild
+	}
+	return r.Action
+}
+
+// actionFor returns the action of the first rule matching the changed file, or the action of the config.
+func (r *WatcherConfig) actionFor(path string) string {
+	rel, ok := r.relPath(path)
+	if !ok {
+		return r.action()
+	}
+	for _, rule := range r.Rules {
+		if ok, err := matchPattern(rule.Pattern, rel); err == nil && ok {
+			return rule.Action
+		}
+	}
+	return r.action()
+}
+
+// IsExclude matches path relative to the watch root with the exclude patterns.
 func (r *WatcherConfig) IsExclude(path string) (bool, error) {
 	for _, f := range r.Excludes {
-		ok, err := filepath.Match(f, path)
+		ok, err := matchPattern(f, path)
 		if err != nil {
 			return false, err
 		}
@@ -75,12 +114,22 @@ func (r *WatcherConfig) IsExclude(path string) (bool, error) {
 	return false, nil
 }
 
+// IsInclude matches path relative to the watch root with the include patterns.
 func (r *WatcherConfig) IsInclude(path string) (bool, error) {
+	return r.isInclude(path, matchPattern)
+}
+
+// IsIncludeDir reports whether files under the directory can match the include patterns.
+func (r *WatcherConfig) IsIncludeDir(path string) (bool, error) {
+	return r.isInclude(path, matchDirPattern)
+}
+
+func (r *WatcherConfig) isInclude(path string, match func(string, string) (bool, error)) (bool, error) {
 	if len(r.Includes) == 0 {
 		return true, nil
 	}
 	for _, f := range r.Includes {
-		ok, err := filepath.Match(f, path)
+		ok, err := match(f, path)
 		if err != nil {
 			return false, err
 		}
@@ -92,47 +141,112 @@ func (r *WatcherConfig) IsInclude(path string) (bool, error) {
 }
 
 func (r *WatcherConfig) ShouldWatchFile(path string, opt *Option) (bool, error) {
-	shouldWatch, err := r.shouldWatch(path, opt)
+	shouldWatch, err := r.shouldWatch(path, false, opt)
 	if err != nil {
 		return false, err
 	}
 	if !shouldWatch {
 		return false, nil
 	}
-	if !opt.exts.IsIncludeSameExt(path) {
+	if rel, ok := r.relPath(path); ok && r.isFile(rel) {
+		return true, nil
+	}
+	exts := opt.exts
+	if len(r.Extensions) > 0 {
+		exts = r.Extensions
+	}
+	if !exts.IsIncludeSameExt(path) {
 		return false, nil
 	}
 	return true, nil
 }
 
-func (r *WatcherConfig) shouldWatch(path string, opt *Option) (bool, error) {
-	path = filepath.Base(path)
-	isGlobalExclude, err := opt.globalExclude.IsExclude(path)
+func (r *WatcherConfig) isGenerated() bool {
+	return r.flat || r.files != nil
+}
+
+func (r *WatcherConfig) isFile(rel string) bool {
+	_, exists := r.files[rel]
+	return exists
+}
+
+// isGeneratedWatch decides whether to watch the path relative to the package directory for the generated configs.
+func (r *WatcherConfig) isGeneratedWatch(rel string, isDir bool) bool {
+	if isDir {
+		prefix := rel + string(filepath.Separator)
+		for file := range r.files {
+			if strings.HasPrefix(file, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+	if r.isFile(rel) {
+		return true
+	}
+	return r.flat && !strings.ContainsRune(rel, filepath.Separator)
+}
+
+func (r *WatcherConfig) root() string {
+	return filepath.Clean(r.Name)
+}
+
+func (r *WatcherConfig) relPath(path string) (string, bool) {
+	rel, err := filepath.Rel(r.root(), filepath.Clean(path))
 	if err != nil {
+		return "", false
+	}
+	if rel == ".." || strings.HasPrefix(rel, fmt.Sprintf("..%c", filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
+
+func (r *WatcherConfig) shouldWatch(path string, isDir bool, opt *Option) (bool, error) {
+	rel, ok := r.relPath(path)
+	if !ok {
 		return false, nil
 	}
+	// the files referred explicitly, e.g. by env_file, are watched even if they are ignored.
+	if !isDir && r.isFile(rel) {
+		return true, nil
+	}
+	isGlobalExclude, err := opt.globalExclude.IsExclude(filepath.Clean(path))
+	if err != nil {
+		return false, err
+	}
 	if isGlobalExclude {
 		return false, nil
 	}
-	isExclude, err := r.IsExclude(path)
-	if err != nil {
+	if opt.ignores.IsIgnored(filepath.Clean(path), isDir) {
 		return false, nil
 	}
+	if r.isGenerated() {
+		return r.isGeneratedWatch(rel, isDir), nil
+	}
+	isExclude, err := r.IsExclude(rel)
+	if err != nil {
+		return false, err
+	}
 	if isExclude {
 		return false, nil
 	}
-	isInclude, err := r.IsInclude(path)
+	isInclude := r.IsInclude
+	if isDir {
+		isInclude = r.IsIncludeDir
+	}
+	included, err := isInclude(rel)
 	if err != nil {
-		return false, nil
+		return false, err
 	}
-	if !isInclude {
+	if !included {
 		return false, nil
 	}
 	return true, nil
 }
 
 func (r *WatcherConfig) SkipDir(dirName string, opt *Option) (bool, error) {
-	shouldWatch, err := r.shouldWatch(dirName, opt)
+	shouldWatch, err := r.shouldWatch(dirName, true, opt)
 	if err != nil {
 		return false, err
 	}
@@ -156,12 +270,13 @@ func (r *WatcherConfig) WalkWithDirName(watcher *fsnotify.Watcher, opt *Option,
 	if isExclude {
 		return watcherPath, nil
 	}
-	if err := filepath.Walk(filepath.Join(dirName, r.Name), func(path string, info os.FileInfo, err error) error {
+	root := filepath.Join(dirName, r.Name)
+	if err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
 		if err != nil && err != filepath.SkipDir {
 			return err
 		}
 		if info.IsDir() {
-			if info.Name() == r.Name {
+			if path == root {
 				if err := watcher.Add(path); err != nil {
 					return err
 				}
@@ -185,16 +300,27 @@ func (r *WatcherConfig) WalkWithDirName(watcher *fsnotify.Watcher, opt *Option,
 			return err
 		}
 		if !shouldWatch {
-			log.IgnoreFile(path)
+			if _, embedded := watcherPath.watches[path]; embedded {
+				return nil
+			}
 			watcherPath.ignores[path] = struct{}{}
 			return nil
 		}
 		log.WatchFile(path)
-		watcherPath.watches[path] = struct{}{}
-		return nil
+		watcherPath.add(path, r)
+		return watcherPath.watchEmbeds(path, []*WatcherConfig{r}, watcher)
 	}); err != nil {
 		return nil, err
 	}
+	// the ignored files are logged after the walk, since they may be embedded by the go files walked later.
+	ignores := make([]string, 0, len(watcherPath.ignores))
+	for path := range watcherPath.ignores {
+		ignores = append(ignores, path)
+	}
+	sort.Strings(ignores)
+	for _, path := range ignores {
+		log.IgnoreFile(path)
+	}
 	return watcherPath, nil
 
 }
@@ -209,7 +335,8 @@ func (r *WatcherConfig) Walk(watcher *fsnotify.Watcher, opt *Option) (*WatcherPa
 
 type WatcherPath struct {
 	ignores map[string]struct{}
-	watches map[string]struct{}
+	watches map[string][]*WatcherConfig
+	embeds  map[string]*embedPatterns
 	wcs     []*WatcherConfig
 	opt     *Option
 }
@@ -217,7 +344,8 @@ type WatcherPath struct {
 func NewWatcherPath(wcs []*WatcherConfig, option *Option) *WatcherPath {
 	return &WatcherPath{
 		ignores: map[string]struct{}{},
-		watches: map[string]struct{}{},
+		watches: map[string][]*WatcherConfig{},
+		embeds:  map[string]*embedPatterns{},
 		wcs:     wcs,
 		opt:     option,
 	}
@@ -225,11 +353,95 @@ func NewWatcherPath(wcs []*WatcherConfig, option *Option) *WatcherPath {
 
 func (w *WatcherPath) Merge(wp *WatcherPath) {
 	for ignore := range wp.ignores {
+		if _, watched := w.watches[ignore]; watched {
+			continue
+		}
 		w.ignores[ignore] = struct{}{}
 	}
-	for watch := range wp.watches {
-		w.watches[watch] = struct{}{}
+	for watch, wcs := range wp.watches {
+		delete(w.ignores, watch)
+		for _, wc := range wcs {
+			w.add(watch, wc)
+		}
+	}
+	for name, embeds := range wp.embeds {
+		if current, exists := w.embeds[name]; exists {
+			embeds.wcs = append(current.wcs, embeds.wcs...)
+		}
+		w.embeds[name] = embeds
+	}
+}
+
+// add keeps the most specific WatcherConfig for each service if the path is watched by several ones.
+func (w *WatcherPath) add(path string, wc *WatcherConfig) {
+	wcs := w.watches[path]
+	for idx, current := range wcs {
+		if current.service != wc.service {
+			continue
+		}
+		if len(current.root()) < len(wc.root()) {
+			wcs[idx] = wc
+		}
+		return
+	}
+	w.watches[path] = append(wcs, wc)
+}
+
+// replace drops the paths watched by the service to walk its new configs.
+func (w *WatcherPath) replace(s *service, wcs []*WatcherConfig) {
+	var removed []*WatcherConfig
+	for _, wc := range w.wcs {
+		if wc.service == s {
+			removed = append(removed, wc)
+		}
+	}
+	w.replaceConfigs(removed, wcs)
+}
+
+// replaceConfigs drops the paths watched by the removed configs, and adds the configs to check the created files.
+func (w *WatcherPath) replaceConfigs(removed, added []*WatcherConfig) {
+	isRemoved := map[*WatcherConfig]struct{}{}
+	for _, wc := range removed {
+		isRemoved[wc] = struct{}{}
+	}
+	keep := func(wcs []*WatcherConfig) []*WatcherConfig {
+		var kept []*WatcherConfig
+		for _, wc := range wcs {
+			if _, exists := isRemoved[wc]; !exists {
+				kept = append(kept, wc)
+			}
+		}
+		return kept
+	}
+	w.wcs = append(keep(w.wcs), added...)
+	for path, configs := range w.watches {
+		kept := keep(configs)
+		if len(kept) == 0 {
+			delete(w.watches, path)
+			continue
+		}
+		w.watches[path] = kept
 	}
+	for name, embeds := range w.embeds {
+		kept := keep(embeds.wcs)
+		if len(kept) == 0 {
+			delete(w.embeds, name)
+			continue
+		}
+		embeds.wcs = kept
+	}
+	w.ignores = map[string]struct{}{}
+}
+
+// Configs returns the WatcherConfigs watching the path.
+func (w *WatcherPath) Configs(path string) ([]*WatcherConfig, bool) {
+	wcs, exists := w.watches[path]
+	return wcs, exists
+}
+
+// forget drops the removed file, so that it is added again when it is created.
+func (w *WatcherPath) forget(path string) {
+	delete(w.watches, path)
 }
 
 var (
@@ -255,14 +467,19 @@ func (w *WatcherPath) AddIfNeeds(path string, watcher *fsnotify.Watcher) error {
 			return err
 		}
 		if shouldWatch {
-			log.WatchFile(path)
-			if err := watcher.Add(path); err != nil {
-				return err
-			}
-			w.watches[path] = struct{}{}
-			return nil
+			w.add(path, wc)
 		}
 	}
+	for _, wc := range w.embeddedConfigs(path) {
+		w.add(path, wc)
+	}
+	if _, exists := w.watches[path]; exists {
+		log.WatchFile(path)
+		if err := watcher.Add(path); err != nil {
+			return err
+		}
+		return nil
+	}
 	log.IgnoreFile(path)
 	w.ignores[path] = struct{}{}
 	return skipToAddErr
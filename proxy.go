@@ -0,0 +1,266 @@
+package fresher
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	liveReloadPath      = "/__fresher/livereload"
+	proxyDialTimeout    = 10 * time.Second
+	proxyDialRetryDelay = 100 * time.Millisecond
+)
+
+var liveReloadScript = []byte(fmt.Sprintf(`<script>(function(){var es=new EventSource(%q);es.addEventListener("reload",function(){location.reload();});})();</script>`, liveReloadPath))
+
+var buildErrorPage = template.Must(template.New("build_error").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Build Failed</title>
+<style>
+body { margin: 0; padding: 2em; background: #1e1e1e; color: #d4d4d4; font-family: monospace; }
+h1 { color: #f48771; font-size: 1.4em; }
+li { margin-bottom: 1em; list-style: none; }
+.pos { color: #4ec9b0; }
+pre { white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<h1>{{ .Title }}</h1>
+{{ if .Diagnostics }}
+<ul>
+{{ range .Diagnostics }}<li><span class="pos">{{ .Position }}</span><pre>{{ .Message }}</pre></li>
+{{ end }}
+</ul>
+{{ else }}
+<pre>{{ .Output }}</pre>
+{{ end }}
+</body>
+</html>
+`))
+
+type ProxyConfig struct {
+	Listen string `yaml:"listen"`
+	Target string `yaml:"target"`
+}
+
+func (pc *ProxyConfig) targetURL() (*url.URL, error) {
+	target := pc.Target
+	if !strings.Contains(target, "://") {
+		target = fmt.Sprintf("http://%s", target)
+	}
+	return url.Parse(target)
+}
+
+// Proxy forwards requests to the application while it is running and holds them while it is being rebuilt.
+type Proxy struct {
+	conf     *ProxyConfig
+	handler  *httputil.ReverseProxy
+	mu       *sync.Mutex
+	ready    chan struct{}
+	buildErr error
+	clients  map[chan struct{}]struct{}
+}
+
+func NewProxy(conf *ProxyConfig) (*Proxy, error) {
+	target, err := conf.targetURL()
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy target %s: %v", conf.Target, err)
+	}
+	p := &Proxy{
+		conf:    conf,
+		mu:      new(sync.Mutex),
+		ready:   make(chan struct{}),
+		clients: map[chan struct{}]struct{}{},
+	}
+	handler := httputil.NewSingleHostReverseProxy(target)
+	director := handler.Director
+	handler.Director = func(req *http.Request) {
+		director(req)
+		// the response body must not be compressed to inject the live reload script.
+		req.Header.Del("Accept-Encoding")
+	}
+	handler.Transport = &http.Transport{
+		Proxy:       http.ProxyFromEnvironment,
+		DialContext: p.dialContext,
+	}
+	handler.ModifyResponse = p.injectScript
+	p.handler = handler
+	return p, nil
+}
+
+func (p *Proxy) ListenAndServe() error {
+	log.Info(fmt.Sprintf("Proxy [%s] to [%s]", p.conf.Listen, p.conf.Target))
+	return http.ListenAndServe(p.conf.Listen, p)
+}
+
+// dialContext retries while the application is starting and does not listen yet.
+func (p *Proxy) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, proxyDialTimeout)
+	defer cancel()
+	var dialer net.Dialer
+	for {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err == nil {
+			return conn, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(proxyDialRetryDelay):
+		}
+	}
+}
+
+func (p *Proxy) injectScript(res *http.Response) error {
+	if !strings.HasPrefix(res.Header.Get("Content-Type"), "text/html") {
+		return nil
+	}
+	if res.Header.Get("Content-Encoding") != "" {
+		return nil
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	body = injectLiveReload(body)
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+	res.ContentLength = int64(len(body))
+	res.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
+
+func injectLiveReload(body []byte) []byte {
+	idx := bytes.LastIndex(bytes.ToLower(body), []byte("</body>"))
+	if idx < 0 {
+		return append(body, liveReloadScript...)
+	}
+	injected := make([]byte, 0, len(body)+len(liveReloadScript))
+	injected = append(injected, body[:idx]...)
+	injected = append(injected, liveReloadScript...)
+	return append(injected, body[idx:]...)
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == liveReloadPath {
+		p.serveLiveReload(w, req)
+		return
+	}
+	p.mu.Lock()
+	ready := p.ready
+	p.mu.Unlock()
+	select {
+	case <-ready:
+	case <-req.Context().Done():
+		return
+	}
+	p.mu.Lock()
+	buildErr := p.buildErr
+	p.mu.Unlock()
+	if buildErr != nil {
+		p.serveBuildError(w, buildErr)
+		return
+	}
+	p.handler.ServeHTTP(w, req)
+}
+
+func (p *Proxy) serveBuildError(w http.ResponseWriter, buildErr error) {
+	data := struct {
+		Title       string
+		Diagnostics []*Diagnostic
+		Output      string
+	}{
+		Title:  buildErr.Error(),
+		Output: buildErr.Error(),
+	}
+	if e, ok := buildErr.(*BuildError); ok {
+		data.Diagnostics = e.Diagnostics
+		data.Output = string(e.Stderr)
+	}
+	var buf bytes.Buffer
+	if err := buildErrorPage.Execute(&buf, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Write(injectLiveReload(buf.Bytes()))
+}
+
+func (p *Proxy) serveLiveReload(w http.ResponseWriter, req *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	reload := make(chan struct{}, 1)
+	p.mu.Lock()
+	p.clients[reload] = struct{}{}
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		delete(p.clients, reload)
+		p.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	select {
+	case <-reload:
+		fmt.Fprint(w, "event: reload\ndata: {}\n\n")
+		flusher.Flush()
+	case <-req.Context().Done():
+	}
+}
+
+// Building holds the incoming requests until Started or Failed is called.
+func (p *Proxy) Building() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	select {
+	case <-p.ready:
+		p.ready = make(chan struct{})
+	default:
+	}
+	p.buildErr = nil
+}
+
+func (p *Proxy) Started() {
+	p.release(nil)
+}
+
+func (p *Proxy) Failed(err error) {
+	p.release(err)
+}
+
+func (p *Proxy) release(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.buildErr = err
+	select {
+	case <-p.ready:
+	default:
+		close(p.ready)
+	}
+	for client := range p.clients {
+		select {
+		case client <- struct{}{}:
+		default:
+		}
+	}
+}
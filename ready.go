@@ -0,0 +1,106 @@
+package fresher
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultReadyTimeout  = 30 * time.Second
+	defaultReadyInterval = 500 * time.Millisecond
+)
+
+// ReadinessProbe checks whether the running application is ready with one of TCP, HTTP or Command.
+type ReadinessProbe struct {
+	TCP      string   `yaml:"tcp"`
+	HTTP     string   `yaml:"http"`
+	Command  *Command `yaml:"command"`
+	Timeout  Duration `yaml:"timeout"`
+	Interval Duration `yaml:"interval"`
+}
+
+func (p *ReadinessProbe) timeout() time.Duration {
+	if p.Timeout > 0 {
+		return time.Duration(p.Timeout)
+	}
+	return defaultReadyTimeout
+}
+
+func (p *ReadinessProbe) interval() time.Duration {
+	if p.Interval > 0 {
+		return time.Duration(p.Interval)
+	}
+	return defaultReadyInterval
+}
+
+func (p *ReadinessProbe) check(ctx context.Context) error {
+	switch {
+	case p.TCP != "":
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", p.TCP)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	case p.HTTP != "":
+		req, err := http.NewRequest(http.MethodGet, p.HTTP, nil)
+		if err != nil {
+			return err
+		}
+		res, err := http.DefaultClient.Do(req.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+		if res.StatusCode < 200 || res.StatusCode >= 300 {
+			return fmt.Errorf("unexpected status code %d", res.StatusCode)
+		}
+		return nil
+	case p.Command != nil:
+		return p.Command.build(ctx).Run()
+	}
+	return nil
+}
+
+func (p *ReadinessProbe) Wait(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout())
+	defer cancel()
+	for {
+		err := p.check(ctx)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("application is not ready after %s: %v", p.timeout(), err)
+		case <-time.After(p.interval()):
+		}
+	}
+}
+
+// ReadyCommand waits for the application started by the previous command to be ready.
+type ReadyCommand struct {
+	probe *ReadinessProbe
+}
+
+func (c *ReadyCommand) ExecContext(ctx context.Context) error {
+	if err := c.probe.Wait(ctx); err != nil {
+		return err
+	}
+	logFromContext(ctx).Ready()
+	return nil
+}
+
+func (c *ReadyCommand) Exec() error {
+	if err := c.ExecContext(context.Background()); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *ReadyCommand) Kill() error {
+	return nil
+}
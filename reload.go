@@ -0,0 +1,305 @@
+package fresher
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDelay waits for the editors to finish writing the config file.
+const reloadDelay = 100 * time.Millisecond
+
+// equalExported compares only the exported fields, since the unexported ones hold the states such as the running process.
+func equalExported(a, b reflect.Value) bool {
+	if !a.IsValid() || !b.IsValid() {
+		return a.IsValid() == b.IsValid()
+	}
+	if a.Type() != b.Type() {
+		return false
+	}
+	switch a.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		return equalExported(a.Elem(), b.Elem())
+	case reflect.Struct:
+		for idx := 0; idx < a.NumField(); idx++ {
+			if a.Type().Field(idx).PkgPath != "" {
+				continue
+			}
+			if !equalExported(a.Field(idx), b.Field(idx)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Slice:
+		if a.Len() != b.Len() {
+			return false
+		}
+		for idx := 0; idx < a.Len(); idx++ {
+			if !equalExported(a.Index(idx), b.Index(idx)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Map:
+		if a.Len() != b.Len() {
+			return false
+		}
+		for _, key := range a.MapKeys() {
+			if !equalExported(a.MapIndex(key), b.MapIndex(key)) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
+
+func sameConfig(a, b interface{}) bool {
+	return equalExported(reflect.ValueOf(a), reflect.ValueOf(b))
+}
+
+// isConfigFile compares the absolute paths, since the config file may be given as an absolute one.
+func (f *Fresher) isConfigFile(name string) bool {
+	if f.opt.configFile == "" {
+		return false
+	}
+	path, err := filepath.Abs(name)
+	if err != nil {
+		return false
+	}
+	configFile, err := filepath.Abs(f.opt.configFile)
+	if err != nil {
+		return false
+	}
+	return path == configFile
+}
+
+// configDir returns the directory of the config file to watch, relative to wd if it is in wd.
+// fsnotify reports the events of a directory added twice by the name of the last one,
+// so the absolute name would hide the events of the directory already watched by the relative one.
+func configDir(configFile, wd string) string {
+	dir := filepath.Dir(configFile)
+	if !filepath.IsAbs(dir) {
+		return dir
+	}
+	rel, err := filepath.Rel(wd, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, fmt.Sprintf("..%c", filepath.Separator)) {
+		return dir
+	}
+	return rel
+}
+
+func (f *Fresher) reserveReload() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.reloadTimer != nil {
+		f.reloadTimer.Stop()
+	}
+	f.reloadTimer = time.AfterFunc(reloadDelay, func() {
+		f.reload <- struct{}{}
+	})
+}
+
+// reloadConfig applies the changes of the config file. the current config is kept if the new one is invalid.
+// only the changed WatcherConfigs are walked again, and only the services whose BuildConfig is changed are rebuilt.
+func (f *Fresher) reloadConfig(watcher *fsnotify.Watcher, watcherPath *WatcherPath) {
+	conf, err := reloadConfigFile(f.opt.configFile)
+	if err != nil {
+		log.Error(fmt.Errorf("failed to reload %s, keep the current config: %v", f.opt.configFile, err))
+		return
+	}
+	next := New(conf.Options()...).opt
+	scs, err := serviceConfigs(next)
+	if err == nil {
+		var configs []*WatcherConfig
+		for _, sc := range scs {
+			configs = append(configs, sc.Paths...)
+		}
+		err = next.actions.Validate(configs)
+	}
+	if err == nil {
+		err = next.debounce.Validate()
+	}
+	if err != nil {
+		log.Error(fmt.Errorf("invalid %s, keep the current config: %v", f.opt.configFile, err))
+		return
+	}
+	log.Info(fmt.Sprintf("Reload %s", f.opt.configFile))
+
+	rewalk := !sameConfig(f.opt.exts, next.exts) ||
+		!sameConfig(f.opt.globalExclude, next.globalExclude) ||
+		f.opt.gitIgnore != next.gitIgnore ||
+		f.opt.dockerIgnore != next.dockerIgnore
+	f.mu.Lock()
+	f.opt.build = next.build
+	f.opt.configs = next.configs
+	f.opt.actions = next.actions
+	f.opt.globalExclude = next.globalExclude
+	f.opt.gitIgnore = next.gitIgnore
+	f.opt.dockerIgnore = next.dockerIgnore
+	f.opt.exts = next.exts
+	f.opt.interval = next.interval
+	f.opt.debounce = next.debounce
+	f.opt.shell = next.shell
+	f.opt.proxy = next.proxy
+	f.opt.services = next.services
+	f.mu.Unlock()
+	if rewalk {
+		if err := f.loadIgnores(); err != nil {
+			log.Error(err)
+		}
+	}
+
+	current := map[string]*service{}
+	for _, s := range f.services {
+		current[s.name] = s
+	}
+	var (
+		services []*service
+		rebuilds []*service
+	)
+	for _, sc := range scs {
+		s, exists := current[sc.Name]
+		if !exists {
+			s = newService(sc.Name, sc.Build, sc.Paths, sc.Proxy, f.opt)
+			if err := s.startProxy(); err != nil {
+				s.log.Error(err)
+			}
+			if s.build.AutoWatch {
+				s.loadDependencies()
+				s.refreshConfigs()
+			}
+			services = append(services, s)
+			rebuilds = append(rebuilds, s)
+			f.walkConfigs(watcher, watcherPath, nil, s.watcherConfigs())
+			continue
+		}
+		delete(current, sc.Name)
+		services = append(services, s)
+		if !sameConfig(s.proxyConf, sc.Proxy) {
+			s.log.Info("Restart fresher to apply the changes of proxy")
+		}
+		removed, added, rebuild := s.update(sc, next.shell, rewalk)
+		if rebuild {
+			rebuilds = append(rebuilds, s)
+		}
+		f.walkConfigs(watcher, watcherPath, removed, added)
+	}
+	for _, s := range current {
+		watcherPath.replace(s, nil)
+		go s.shutdown()
+	}
+	f.mu.Lock()
+	f.services = services
+	f.mu.Unlock()
+	for _, s := range rebuilds {
+		go s.restart(nil)
+	}
+}
+
+// reloadConfigFile recovers the panic of the decoder for some malformed yaml, not to stop watching by editing the config.
+func reloadConfigFile(filename string) (conf *Config, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("invalid config %s: %v", filename, r)
+		}
+	}()
+	return LoadConfig(filename)
+}
+
+// walkConfigs stops the events for the removed configs and walks the added ones.
+func (f *Fresher) walkConfigs(watcher *fsnotify.Watcher, watcherPath *WatcherPath, removed, added []*WatcherConfig) {
+	watcherPath.replaceConfigs(removed, added)
+	for _, wc := range added {
+		wp, err := wc.Walk(watcher, f.opt)
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		watcherPath.Merge(wp)
+	}
+}
+
+// update applies the new config of the service, and returns the WatcherConfigs to walk again and whether to rebuild.
+// the unchanged WatcherConfigs are kept as they are, but they are walked again if they contain a removed one,
+// because the files of the removed one may belong to them now.
+// the states are replaced under lifecycle, since fire reads them on another goroutine.
+func (s *service) update(sc *ServiceConfig, shell Shell, rewalk bool) ([]*WatcherConfig, []*WatcherConfig, bool) {
+	s.lifecycle.Lock()
+	defer s.lifecycle.Unlock()
+	s.shell = shell
+	before := s.watcherConfigs()
+	rebuild := !sameConfig(s.build, sc.Build)
+	if rebuild {
+		s.setBuild(sc.Build)
+	}
+	if s.build.AutoWatch {
+		if rebuild {
+			s.loadDependencies()
+			s.replaceConfigs()
+		}
+	} else {
+		configs := make([]*WatcherConfig, 0, len(sc.Paths))
+		unused := append([]*WatcherConfig{}, s.configs...)
+		for _, wc := range sc.Paths {
+			for idx, old := range unused {
+				if old.isGenerated() || !sameConfig(old, wc) {
+					continue
+				}
+				wc = old
+				unused = append(unused[:idx], unused[idx+1:]...)
+				break
+			}
+			wc.service = s
+			configs = append(configs, wc)
+		}
+		s.configs = configs
+	}
+	after := s.watcherConfigs()
+	if rewalk {
+		return before, after, rebuild
+	}
+
+	kept := map[*WatcherConfig]struct{}{}
+	for _, wc := range after {
+		kept[wc] = struct{}{}
+	}
+	var removed, added []*WatcherConfig
+	for _, wc := range before {
+		if _, exists := kept[wc]; !exists {
+			removed = append(removed, wc)
+		}
+	}
+	existed := map[*WatcherConfig]struct{}{}
+	for _, wc := range before {
+		existed[wc] = struct{}{}
+	}
+	dropped := append([]*WatcherConfig{}, removed...)
+	for _, wc := range after {
+		_, exists := existed[wc]
+		if !exists || containsRemoved(wc, dropped) {
+			if exists {
+				removed = append(removed, wc)
+			}
+			added = append(added, wc)
+		}
+	}
+	return removed, added, rebuild
+}
+
+func containsRemoved(wc *WatcherConfig, removed []*WatcherConfig) bool {
+	for _, r := range removed {
+		rel, err := filepath.Rel(wc.root(), r.root())
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, fmt.Sprintf("..%c", filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package fresher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigDir(t *testing.T) {
+	wd := filepath.FromSlash("/tmp/proj")
+	tests := []struct {
+		configFile string
+		want       string
+	}{
+		{configFile: "fresher.yaml", want: "."},
+		{configFile: "conf/fresher.yaml", want: "conf"},
+		{configFile: "/tmp/proj/fresher.yaml", want: "."},
+		{configFile: "/tmp/proj/conf/fresher.yaml", want: "conf"},
+		{configFile: "/tmp/other/fresher.yaml", want: "/tmp/other"},
+		{configFile: "/tmp/projx/fresher.yaml", want: "/tmp/projx"},
+	}
+	for _, tt := range tests {
+		got := configDir(filepath.FromSlash(tt.configFile), wd)
+		if want := filepath.FromSlash(tt.want); got != want {
+			t.Errorf("configDir(%q) = %q, want %q", tt.configFile, got, want)
+		}
+	}
+}
+
+func TestIsConfigFileAbsolute(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := New(ConfigFile(filepath.Join(wd, "fresher.yaml")))
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{name: "fresher.yaml", want: true},
+		{name: "./fresher.yaml", want: true},
+		{name: filepath.Join(wd, "fresher.yaml"), want: true},
+		{name: "main.go", want: false},
+		{name: filepath.Join("conf", "fresher.yaml"), want: false},
+	}
+	for _, tt := range tests {
+		if got := f.isConfigFile(tt.name); got != tt.want {
+			t.Errorf("isConfigFile(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package fresher
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/goccy/go-yaml"
+)
+
+const (
+	RestartNever     = "never"
+	RestartOnFailure = "on-failure"
+	RestartAlways    = "always"
+)
+
+const (
+	defaultRestartBackoff    = time.Second
+	defaultRestartMaxBackoff = 30 * time.Second
+)
+
+// RestartPolicy decides whether the application exited unexpectedly is started again.
+// MaxRetries 0 means restarting without limit.
+type RestartPolicy struct {
+	Policy     string   `yaml:"policy"`
+	MaxRetries int      `yaml:"max_retries"`
+	Backoff    Duration `yaml:"backoff"`
+	MaxBackoff Duration `yaml:"max_backoff"`
+}
+
+func (p *RestartPolicy) UnmarshalYAML(b []byte) error {
+	st := struct {
+		Policy     string   `yaml:"policy"`
+		MaxRetries int      `yaml:"max_retries"`
+		Backoff    Duration `yaml:"backoff"`
+		MaxBackoff Duration `yaml:"max_backoff"`
+	}{}
+	var v interface{}
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	if policy, ok := v.(string); ok {
+		st.Policy = policy
+	} else if err := yaml.Unmarshal(b, &st); err != nil {
+		return err
+	}
+	switch st.Policy {
+	case RestartNever, RestartOnFailure, RestartAlways:
+	default:
+		return fmt.Errorf("unsupported restart policy: %s", st.Policy)
+	}
+	p.Policy = st.Policy
+	p.MaxRetries = st.MaxRetries
+	p.Backoff = st.Backoff
+	p.MaxBackoff = st.MaxBackoff
+	return nil
+}
+
+func (p *RestartPolicy) ShouldRestart(state *os.ProcessState) bool {
+	if p == nil {
+		return false
+	}
+	switch p.Policy {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return state == nil || !state.Success()
+	}
+	return false
+}
+
+func (p *RestartPolicy) CanRetry(retries int) bool {
+	return p.MaxRetries <= 0 || retries < p.MaxRetries
+}
+
+func (p *RestartPolicy) maxBackoff() time.Duration {
+	if p != nil && p.MaxBackoff > 0 {
+		return time.Duration(p.MaxBackoff)
+	}
+	return defaultRestartMaxBackoff
+}
+
+// BackoffDelay doubles the delay for each retry up to MaxBackoff.
+func (p *RestartPolicy) BackoffDelay(retries int) time.Duration {
+	delay := defaultRestartBackoff
+	if p.Backoff > 0 {
+		delay = time.Duration(p.Backoff)
+	}
+	for i := 0; i < retries && delay < p.maxBackoff(); i++ {
+		delay *= 2
+	}
+	if delay > p.maxBackoff() {
+		return p.maxBackoff()
+	}
+	return delay
+}
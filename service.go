@@ -0,0 +1,481 @@
+package fresher
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ServiceConfig is one of the applications built and run by a single fresher.
+type ServiceConfig struct {
+	Name  string           `yaml:"name"`
+	Build *BuildConfig     `yaml:"build"`
+	Paths []*WatcherConfig `yaml:"path"`
+	Host  *Host            `yaml:"host"`
+	Proxy *ProxyConfig     `yaml:"proxy"`
+}
+
+// service builds and runs the application of BuildConfig, and restarts it when its watched files are changed.
+type service struct {
+	name       string
+	build      *BuildConfig
+	configs    []*WatcherConfig
+	envConfigs []*WatcherConfig
+	opt        *Option
+	shell      Shell
+	log        *Log
+	proxyConf  *ProxyConfig
+	proxy      *Proxy
+	deps       *Dependencies
+	watched    int
+	// changesFile is the file of the last changes passed to the hooks, removed when the next ones are passed.
+	changesFile string
+	buildNumber int
+	lifecycle   *sync.Mutex
+	running     []Executor
+	runContext  context.Context
+	cancel      context.CancelFunc
+	generation  int
+}
+
+func newService(name string, build *BuildConfig, configs []*WatcherConfig, proxy *ProxyConfig, opt *Option) *service {
+	s := &service{
+		name:      name,
+		configs:   configs,
+		opt:       opt,
+		log:       log,
+		proxyConf: proxy,
+		shell:     opt.shell,
+		lifecycle: new(sync.Mutex),
+	}
+	if name != "" {
+		s.log = log.WithPrefix(name)
+	}
+	for _, wc := range configs {
+		wc.service = s
+	}
+	s.setBuild(build)
+	return s
+}
+
+// setBuild replaces BuildConfig with the states depending on it.
+func (s *service) setBuild(build *BuildConfig) {
+	s.build = build
+	s.deps = nil
+	s.watched = 0
+	if build.DepsOnly || build.AutoWatch {
+		s.deps = NewDependencies(build.Target, build.Arg, build.buildEnviron())
+	}
+	s.envConfigs = nil
+	for _, name := range build.EnvFiles {
+		dir, file := filepath.Split(filepath.Clean(name))
+		if dir == "" {
+			dir = "."
+		}
+		s.envConfigs = append(s.envConfigs, &WatcherConfig{
+			Name:    dir,
+			Action:  ActionRestart,
+			files:   map[string]struct{}{file: {}},
+			service: s,
+		})
+	}
+}
+
+// watcherConfigs returns the configs to walk. the env files come first to restart when they are also watched by the other configs.
+func (s *service) watcherConfigs() []*WatcherConfig {
+	return append(append([]*WatcherConfig{}, s.envConfigs...), s.configs...)
+}
+
+func (s *service) context() context.Context {
+	return contextWithShell(contextWithLog(context.Background(), s.log), s.shell)
+}
+
+// changeHook writes the changes for the hooks. the hook is passed as is to the builds of the other changes performed at the same time.
+func (s *service) changeHook(changes ChangeSet) *Hook {
+	name, err := changes.writeFile()
+	if err != nil {
+		s.log.Error(fmt.Errorf("failed to write changed files: %v", err))
+		name = ""
+	}
+	return newHook(HookEventChange, 0, changes, name)
+}
+
+// hook returns why the commands run now, for the changes of changed or for the start or the reload if it is nil.
+// s.lifecycle must be held.
+func (s *service) hook(changed *Hook) *Hook {
+	if changed == nil {
+		event := HookEventReload
+		if s.buildNumber <= 1 {
+			event = HookEventStart
+		}
+		return newHook(event, s.buildNumber, nil, "")
+	}
+	h := *changed
+	h.BuildNumber = s.buildNumber
+	return &h
+}
+
+// commandContext passes the hook to the before and after commands, and returns ctx as is for the others.
+func (s *service) commandContext(ctx context.Context, cmd Executor, hook *Hook) context.Context {
+	if !s.build.isHook(cmd) {
+		return ctx
+	}
+	return contextWithHook(ctx, hook)
+}
+
+// keepChangesFile keeps the file of the hook for the hooks still running, and removes the last one no longer read.
+// s.lifecycle must be held.
+func (s *service) keepChangesFile(hook *Hook) {
+	if hook.ChangesFile == "" || hook.ChangesFile == s.changesFile {
+		return
+	}
+	s.removeChangesFile()
+	s.changesFile = hook.ChangesFile
+}
+
+// releaseChangesFile removes the file of the hook unless it is kept for the hooks.
+func (s *service) releaseChangesFile(hook *Hook) {
+	s.lifecycle.Lock()
+	defer s.lifecycle.Unlock()
+	if hook.ChangesFile == "" || hook.ChangesFile == s.changesFile {
+		return
+	}
+	removeFile(hook.ChangesFile, s.log)
+}
+
+func (s *service) removeChangesFile() {
+	if s.changesFile == "" {
+		return
+	}
+	removeFile(s.changesFile, s.log)
+	s.changesFile = ""
+}
+
+func removeFile(name string, l *Log) {
+	if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+		l.Error(err)
+	}
+}
+
+// isDependent reports whether the change of path needs to rebuild the binary.
+func (s *service) isDependent(path string) bool {
+	s.lifecycle.Lock()
+	deps := s.deps
+	s.lifecycle.Unlock()
+	if deps == nil {
+		return true
+	}
+	return deps.IsDependent(path)
+}
+
+func (s *service) loadDependencies() {
+	if s.deps == nil {
+		return
+	}
+	if err := s.deps.LoadIfNeeds(); err != nil {
+		s.log.Error(err)
+	}
+}
+
+// refreshConfigs replaces the configs with the ones generated from the dependencies if they are changed since the last time.
+func (s *service) refreshConfigs() bool {
+	s.lifecycle.Lock()
+	defer s.lifecycle.Unlock()
+	return s.replaceConfigs()
+}
+
+// replaceConfigs is refreshConfigs for the caller holding lifecycle.
+func (s *service) replaceConfigs() bool {
+	if !s.build.AutoWatch {
+		return false
+	}
+	version := s.deps.Version()
+	if version == s.watched {
+		return false
+	}
+	s.watched = version
+	s.configs = s.deps.WatchConfigs()
+	for _, wc := range s.configs {
+		wc.service = s
+	}
+	return true
+}
+
+// loadEnvFiles reports false to keep the running processes if the env files are broken.
+func (s *service) loadEnvFiles() bool {
+	if err := s.build.LoadEnvFiles(); err != nil {
+		s.log.Error(err)
+		return false
+	}
+	return true
+}
+
+func (s *service) startProxy() error {
+	if s.proxyConf == nil {
+		return nil
+	}
+	proxy, err := NewProxy(s.proxyConf)
+	if err != nil {
+		return err
+	}
+	s.proxy = proxy
+	go func() {
+		if err := proxy.ListenAndServe(); err != nil {
+			s.log.Error(fmt.Errorf("failed to start proxy: %v", err))
+		}
+	}()
+	return nil
+}
+
+// restart stops the running processes, waits for them to exit and then builds and starts them again.
+// changed is the hook of the changed files, or nil for the start and the reload.
+func (s *service) restart(changed *Hook) {
+	s.lifecycle.Lock()
+	defer s.lifecycle.Unlock()
+	if !s.loadEnvFiles() {
+		return
+	}
+	s.loadDependencies()
+	s.buildNumber++
+	hook := s.hook(changed)
+	s.keepChangesFile(hook)
+	if s.build.KeepOnFailure {
+		s.restartIfBuilt(hook)
+		return
+	}
+	s.building()
+	s.stop()
+	s.log.Building()
+	if err := s.start(s.build.buildCommands(s.build.runBinaryPath()), hook); err != nil {
+		s.buildFailed(err)
+		return
+	}
+	if err := s.start(s.build.ReleaseCommands(), hook); err != nil {
+		s.runFailed(err)
+		return
+	}
+	s.started()
+}
+
+// restartIfBuilt keeps the running processes unless the new binary is built successfully.
+func (s *service) restartIfBuilt(hook *Hook) {
+	s.log.Building()
+	for _, cmd := range s.build.StagingCommands() {
+		if err := cmd.ExecContext(s.commandContext(s.context(), cmd, hook)); err != nil {
+			if len(s.running) == 0 {
+				s.buildFailed(err)
+				return
+			}
+			// the proxy keeps forwarding the requests to the last binary.
+			s.reportBuildError(err)
+			s.log.Info("Keep running the last successfully built binary")
+			return
+		}
+	}
+	s.building()
+	s.stop()
+	if err := s.build.ReleaseStagingBinary(); err != nil {
+		s.buildFailed(err)
+		return
+	}
+	if err := s.start(s.build.ReleaseCommands(), hook); err != nil {
+		s.runFailed(err)
+		return
+	}
+	s.started()
+}
+
+// restartApp restarts the running binary without building it again.
+func (s *service) restartApp(changed *Hook) {
+	s.lifecycle.Lock()
+	defer s.lifecycle.Unlock()
+	if !s.loadEnvFiles() {
+		return
+	}
+	hook := s.hook(changed)
+	s.keepChangesFile(hook)
+	s.building()
+	s.stop()
+	if err := s.start(s.build.ReleaseCommands(), hook); err != nil {
+		s.runFailed(err)
+		return
+	}
+	s.started()
+}
+
+// perform runs the commands of the actions and then rebuilds or restarts if any of them needs.
+// the hooks run for the changes can read them from the file of ChangesFileEnv.
+// the hook is passed explicitly, since the changes performed at the same time are built one by one.
+func (s *service) perform(plan *actionPlan, changes ChangeSet) {
+	changed := s.changeHook(changes)
+	defer s.releaseChangesFile(changed)
+	s.lifecycle.Lock()
+	ctx := contextWithHook(s.context(), s.hook(changed))
+	s.lifecycle.Unlock()
+	for _, action := range plan.actions {
+		s.log.RunAction(action.Name)
+		for _, cmd := range action.Commands {
+			if err := cmd.ExecContext(ctx); err != nil {
+				s.log.Error(fmt.Errorf("failed to run action %s: %v", action.Name, err))
+				return
+			}
+		}
+	}
+	switch {
+	case plan.rebuild:
+		s.restart(changed)
+	case plan.restart:
+		s.restartApp(changed)
+	case plan.runHook:
+		s.runHooks(changed)
+	}
+}
+
+// runHooks runs the after commands for the running binary.
+func (s *service) runHooks(changed *Hook) {
+	s.lifecycle.Lock()
+	defer s.lifecycle.Unlock()
+	hook := s.hook(changed)
+	s.keepChangesFile(hook)
+	ctx := contextWithHook(s.context(), hook)
+	for _, cmd := range s.build.AfterCommands {
+		if err := cmd.ExecContext(ctx); err != nil {
+			s.log.Error(fmt.Errorf("failed to run hook: %v", err))
+			return
+		}
+	}
+}
+
+func (s *service) building() {
+	if s.proxy != nil {
+		s.proxy.Building()
+	}
+}
+
+func (s *service) started() {
+	if s.proxy != nil {
+		s.proxy.Started()
+	}
+}
+
+// runFailed reports the error of running the built binary or the after hooks.
+// it is not a build error, so the proxy forwards the requests to the binary if it is running.
+func (s *service) runFailed(err error) {
+	s.started()
+	s.log.Error(fmt.Errorf("failed to run: %v", err))
+}
+
+// buildFailed shows the error of the build step by the proxy, and reports it.
+func (s *service) buildFailed(err error) {
+	if s.proxy != nil {
+		s.proxy.Failed(err)
+	}
+	s.reportBuildError(err)
+}
+
+// reportBuildError logs the error of the build step and passes it to the handlers.
+func (s *service) reportBuildError(err error) {
+	buildErr, ok := err.(*BuildError)
+	if !ok {
+		s.log.Error(fmt.Errorf("failed to build: %v", err))
+		return
+	}
+	s.log.BuildFailed(buildErr)
+	for _, handler := range s.opt.onBuildError {
+		handler(buildErr)
+	}
+}
+
+func (s *service) shutdown() {
+	s.lifecycle.Lock()
+	defer s.lifecycle.Unlock()
+	s.stop()
+	s.removeChangesFile()
+}
+
+// start runs the commands following the ones started since the last stop, in the context canceled by stop.
+func (s *service) start(commands []Executor, hook *Hook) error {
+	if s.cancel == nil {
+		s.runContext, s.cancel = context.WithCancel(s.context())
+	}
+	s.running = append(s.running, commands...)
+	for _, cmd := range commands {
+		if err := cmd.ExecContext(s.commandContext(s.runContext, cmd, hook)); err != nil {
+			return err
+		}
+		if pw, ok := cmd.(processWatcher); ok && pw.Exited() != nil {
+			go s.monitor(pw, s.generation)
+		}
+	}
+	return nil
+}
+
+type processWatcher interface {
+	Executor
+	Exited() <-chan struct{}
+	ProcessState() *os.ProcessState
+	RestartPolicy() *RestartPolicy
+}
+
+// monitor reports the process exited without being stopped by fresher and restarts it according to its policy.
+func (s *service) monitor(cmd processWatcher, generation int) {
+	var retries int
+	for {
+		startedAt := time.Now()
+		<-cmd.Exited()
+		s.lifecycle.Lock()
+		if generation != s.generation {
+			s.lifecycle.Unlock()
+			return
+		}
+		state := cmd.ProcessState()
+		s.lifecycle.Unlock()
+
+		s.log.Error(fmt.Sprintf("Exec Process [%d] exited unexpectedly (%s)", state.Pid(), state))
+		policy := cmd.RestartPolicy()
+		if !policy.ShouldRestart(state) {
+			return
+		}
+		if time.Since(startedAt) > policy.maxBackoff() {
+			retries = 0
+		}
+		if !policy.CanRetry(retries) {
+			s.log.Error(fmt.Sprintf("Give up restarting after %d retries", retries))
+			return
+		}
+		delay := policy.BackoffDelay(retries)
+		retries++
+		s.log.Info(fmt.Sprintf("Restart Process in %s (retry %d)", delay, retries))
+		time.Sleep(delay)
+
+		s.lifecycle.Lock()
+		if generation != s.generation {
+			s.lifecycle.Unlock()
+			return
+		}
+		err := cmd.ExecContext(s.context())
+		s.lifecycle.Unlock()
+		if err != nil {
+			s.log.Error(fmt.Errorf("failed to restart: %v", err))
+			return
+		}
+	}
+}
+
+func (s *service) stop() {
+	s.generation++
+	for idx := len(s.running) - 1; idx >= 0; idx-- {
+		if err := s.running[idx].Kill(); err != nil {
+			s.log.Error(err)
+		}
+	}
+	s.running = nil
+	if s.cancel != nil {
+		s.cancel()
+		s.cancel = nil
+		s.runContext = nil
+	}
+}
@@ -0,0 +1,133 @@
+package fresher
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+// Shell is the interpreter of the commands with shell, which receives the command line as the last argument.
+type Shell []string
+
+var defaultShell = Shell{"sh", "-c"}
+
+func (s *Shell) UnmarshalYAML(b []byte) error {
+	var v interface{}
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	var words []string
+	if line, ok := v.(string); ok {
+		split, err := splitWords(line)
+		if err != nil {
+			return err
+		}
+		words = split
+	} else if err := yaml.Unmarshal(b, &words); err != nil {
+		return err
+	}
+	if len(words) == 0 {
+		return fmt.Errorf("shell is empty")
+	}
+	*s = words
+	return nil
+}
+
+// command returns the name and the arg to run the command line with the shell.
+func (s Shell) command(line string) (string, []string) {
+	if len(s) == 0 {
+		s = defaultShell
+	}
+	return s[0], append(append([]string{}, s[1:]...), line)
+}
+
+type shellContextKey struct{}
+
+func contextWithShell(ctx context.Context, s Shell) context.Context {
+	return context.WithValue(ctx, shellContextKey{}, s)
+}
+
+func shellFromContext(ctx context.Context) Shell {
+	if s, ok := ctx.Value(shellContextKey{}).(Shell); ok && len(s) > 0 {
+		return s
+	}
+	return defaultShell
+}
+
+// shellQuote quotes the word with single quotes unless the shell reads it as is.
+func shellQuote(word string) string {
+	if word != "" && strings.Trim(word, shellSafe) == "" {
+		return word
+	}
+	return "'" + strings.Replace(word, "'", `'\''`, -1) + "'"
+}
+
+const shellSafe = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./=:,+@%"
+
+// splitWords splits the command line into the words as POSIX shells do, without the expansions.
+// single quotes keep the characters as they are, and backslashes escape the next character outside of them.
+// in double quotes, backslashes escape only $, `, ", \ and newline.
+func splitWords(line string) ([]string, error) {
+	var (
+		words   []string
+		word    strings.Builder
+		inWord  bool
+		quote   byte
+		escaped bool
+	)
+	for idx := 0; idx < len(line); idx++ {
+		c := line[idx]
+		switch {
+		case escaped:
+			escaped = false
+			if quote == '"' && !strings.ContainsRune("$`\"\\", rune(c)) {
+				word.WriteByte('\\')
+			}
+			word.WriteByte(c)
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+				continue
+			}
+			word.WriteByte(c)
+		case c == '\\':
+			// backslash and newline continue the line.
+			if idx+1 < len(line) && line[idx+1] == '\n' {
+				idx++
+				continue
+			}
+			escaped = true
+			inWord = true
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+				continue
+			}
+			word.WriteByte(c)
+		case c == '\'' || c == '"':
+			quote = c
+			inWord = true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if escaped {
+		return nil, fmt.Errorf("trailing backslash in %s", line)
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unclosed quote in %s", line)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,55 @@
+package fresher
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitWords(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    []string
+		wantErr bool
+	}{
+		{line: `echo "a  b" > out.txt`, want: []string{"echo", "a  b", ">", "out.txt"}},
+		{line: `a\ b`, want: []string{"a b"}},
+		{line: `'c d'`, want: []string{"c d"}},
+		{line: `"e\"f"`, want: []string{`e"f`}},
+		{line: `"g\h"`, want: []string{`g\h`}},
+		{line: `''`, want: []string{""}},
+		{line: `a '' b`, want: []string{"a", "", "b"}},
+		{line: "a \\\nb", want: []string{"a", "b"}},
+		{line: "a\\\nb", want: []string{"ab"}},
+		{line: `echo "a`, wantErr: true},
+		{line: `echo 'a`, wantErr: true},
+		{line: `echo a\`, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := splitWords(tt.line)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("splitWords(%q) = %q, want error", tt.line, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("splitWords(%q): %v", tt.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitWords(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	for _, word := range []string{"a", "", "a b", "it's", `"$HOME"`, `a\b`, "a\nb"} {
+		got, err := splitWords(shellQuote(word))
+		if err != nil {
+			t.Fatalf("splitWords(shellQuote(%q)): %v", word, err)
+		}
+		if want := []string{word}; !reflect.DeepEqual(got, want) {
+			t.Errorf("splitWords(shellQuote(%q)) = %q, want %q", word, got, want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package fresher
+
+import (
+	"fmt"
+	"strings"
+	"syscall"
+
+	"github.com/goccy/go-yaml"
+)
+
+type Signal string
+
+const (
+	SignalHUP  Signal = "HUP"
+	SignalINT  Signal = "INT"
+	SignalQUIT Signal = "QUIT"
+	SignalKILL Signal = "KILL"
+	SignalTERM Signal = "TERM"
+)
+
+var signals = map[Signal]syscall.Signal{
+	SignalHUP:  syscall.SIGHUP,
+	SignalINT:  syscall.SIGINT,
+	SignalQUIT: syscall.SIGQUIT,
+	SignalKILL: syscall.SIGKILL,
+	SignalTERM: syscall.SIGTERM,
+}
+
+func ToSignal(name string) (Signal, error) {
+	sig := Signal(strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(name)), "SIG"))
+	if _, exists := signals[sig]; !exists {
+		return "", fmt.Errorf("unsupported signal: %s", name)
+	}
+	return sig, nil
+}
+
+func (s *Signal) UnmarshalYAML(b []byte) error {
+	var name string
+	if err := yaml.Unmarshal(b, &name); err != nil {
+		return err
+	}
+	sig, err := ToSignal(name)
+	if err != nil {
+		return err
+	}
+	*s = sig
+	return nil
+}
+
+// Syscall returns SIGKILL if the signal is not set, so that the process stops immediately as before.
+func (s Signal) Syscall() syscall.Signal {
+	if sig, exists := signals[s]; exists {
+		return sig
+	}
+	return syscall.SIGKILL
+}
+
+func (s Signal) Name() string {
+	if _, exists := signals[s]; exists {
+		return string(s)
+	}
+	return string(SignalKILL)
+}
+
+func (s Signal) IsKill() bool {
+	return s.Syscall() == syscall.SIGKILL
+}
+
+func (s Signal) String() string {
+	return fmt.Sprintf("SIG%s", s.Name())
+}
@@ -0,0 +1,358 @@
+package fresher
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+)
+
+// ValidationError is a problem of the config. Line and Column are 0 if the field is not written in the file.
+type ValidationError struct {
+	File    string
+	Line    int
+	Column  int
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	msg := e.Message
+	if e.Field != "" {
+		msg = fmt.Sprintf("%s: %s", e.Field, msg)
+	}
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, msg)
+	}
+	if e.File != "" {
+		return fmt.Sprintf("%s: %s", e.File, msg)
+	}
+	return msg
+}
+
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+type validator struct {
+	filename string
+	root     ast.Node
+	errs     ValidationErrors
+}
+
+func newValidator(filename string, source []byte) (*validator, error) {
+	v := &validator{filename: filename}
+	if source == nil {
+		return v, nil
+	}
+	file, err := parser.ParseBytes(source, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(file.Docs) > 0 {
+		v.root = file.Docs[0].Body
+	}
+	return v, nil
+}
+
+func (v *validator) report(node ast.Node, field, msg string) {
+	err := &ValidationError{
+		File:    v.filename,
+		Field:   field,
+		Message: msg,
+	}
+	if node != nil && node.GetToken() != nil {
+		err.Line = node.GetToken().Position.Line
+		err.Column = node.GetToken().Position.Column
+	}
+	v.errs = append(v.errs, err)
+}
+
+// reportf reports the problem at the node of the field path such as path[1].exclude[0].
+func (v *validator) reportf(field, format string, a ...interface{}) {
+	v.report(v.nodeAt(field), field, fmt.Sprintf(format, a...))
+}
+
+var fieldPathSegment = regexp.MustCompile(`[^.\[\]]+|\[\d+\]`)
+
+// nodeAt returns the deepest node found along the field path, so that the position is as close as possible.
+func (v *validator) nodeAt(field string) ast.Node {
+	node := v.root
+	for _, segment := range fieldPathSegment.FindAllString(field, -1) {
+		next := childNode(node, segment)
+		if next == nil {
+			return node
+		}
+		node = next
+	}
+	return node
+}
+
+func childNode(node ast.Node, segment string) ast.Node {
+	node = unwrapNode(node)
+	if strings.HasPrefix(segment, "[") {
+		seq, ok := node.(*ast.SequenceNode)
+		if !ok {
+			return nil
+		}
+		idx, _ := strconv.Atoi(strings.Trim(segment, "[]"))
+		if idx >= len(seq.Values) {
+			return nil
+		}
+		return seq.Values[idx]
+	}
+	for _, kv := range mappingValues(node) {
+		if mappingKey(kv) == segment {
+			return kv.Value
+		}
+	}
+	return nil
+}
+
+func unwrapNode(node ast.Node) ast.Node {
+	for {
+		switch n := node.(type) {
+		case *ast.AnchorNode:
+			node = n.Value
+		case *ast.TagNode:
+			node = n.Value
+		default:
+			return node
+		}
+	}
+}
+
+// mappingValues returns the key-value pairs of the mapping. a mapping with a single key is parsed as MappingValueNode.
+func mappingValues(node ast.Node) []*ast.MappingValueNode {
+	switch n := unwrapNode(node).(type) {
+	case *ast.MappingNode:
+		return n.Values
+	case *ast.MappingValueNode:
+		return []*ast.MappingValueNode{n}
+	}
+	return nil
+}
+
+func mappingKey(kv *ast.MappingValueNode) string {
+	if tk := kv.Key.GetToken(); tk != nil {
+		return tk.Value
+	}
+	return kv.Key.String()
+}
+
+func isMapping(node ast.Node) bool {
+	switch unwrapNode(node).(type) {
+	case *ast.MappingNode, *ast.MappingValueNode:
+		return true
+	}
+	return false
+}
+
+var hostStructType = reflect.TypeOf(Host{})
+
+// checkUnknownFields compares the keys in the YAML with the yaml tags of the type.
+// the nodes written in another form, e.g. a string for a struct, are not checked here.
+func (v *validator) checkUnknownFields(node ast.Node, t reflect.Type, field string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	node = unwrapNode(node)
+	switch {
+	case t == hostStructType:
+		for _, kv := range mappingValues(node) {
+			if key := mappingKey(kv); toHostType(key) != HostTypeDocker {
+				v.report(kv.Key, fieldPath(field, key), "unknown host type")
+			}
+		}
+	case t.Kind() == reflect.Struct:
+		if !isMapping(node) {
+			return
+		}
+		fields := map[string]reflect.Type{}
+		for idx := 0; idx < t.NumField(); idx++ {
+			f := t.Field(idx)
+			if f.PkgPath != "" || f.Tag.Get("yaml") == "-" {
+				continue
+			}
+			fields[yamlFieldName(f)] = f.Type
+		}
+		for _, kv := range mappingValues(node) {
+			key := mappingKey(kv)
+			ft, exists := fields[key]
+			if !exists {
+				v.report(kv.Key, fieldPath(field, key), "unknown field")
+				continue
+			}
+			v.checkUnknownFields(kv.Value, ft, fieldPath(field, key))
+		}
+	case t.Kind() == reflect.Slice:
+		seq, ok := node.(*ast.SequenceNode)
+		if !ok {
+			return
+		}
+		for idx, value := range seq.Values {
+			v.checkUnknownFields(value, t.Elem(), fmt.Sprintf("%s[%d]", field, idx))
+		}
+	case t.Kind() == reflect.Map:
+		for _, kv := range mappingValues(node) {
+			v.checkUnknownFields(kv.Value, t.Elem(), fieldPath(field, mappingKey(kv)))
+		}
+	}
+}
+
+func (v *validator) checkPatterns(field string, patterns []string) {
+	for idx, pattern := range patterns {
+		if err := validatePattern(pattern); err != nil {
+			v.reportf(fmt.Sprintf("%s[%d]", field, idx), "invalid pattern %s: %v", pattern, err)
+		}
+	}
+}
+
+func (v *validator) checkBuild(bc *BuildConfig, field string) {
+	if err := validateTarget(bc.Target); err != nil {
+		v.reportf(fieldPath(field, "target"), "%v", err)
+	}
+	for idx, name := range bc.EnvFiles {
+		if _, err := os.Stat(name); err != nil {
+			v.reportf(fmt.Sprintf("%s[%d]", fieldPath(field, "env_file"), idx), "%v", err)
+		}
+	}
+	v.checkHost(bc.Host, fieldPath(field, "host"))
+	v.checkCommands(bc.BeforeCommands, fieldPath(field, "before"))
+	v.checkCommands(bc.AfterCommands, fieldPath(field, "after"))
+}
+
+func (v *validator) checkCommands(commands []*Command, field string) {
+	for idx, cmd := range commands {
+		if cmd.When == "" {
+			continue
+		}
+		if err := validatePattern(cmd.When); err != nil {
+			v.reportf(fieldPath(fmt.Sprintf("%s[%d]", field, idx), "when"), "invalid pattern %s: %v", cmd.When, err)
+		}
+	}
+}
+
+func (v *validator) checkHost(h *Host, field string) {
+	if h == nil || h.Type != HostTypeDocker {
+		return
+	}
+	if err := validateDockerContainer(h.LocationName); err != nil {
+		v.reportf(fieldPath(field, hostNameDocker), "%v", err)
+	}
+}
+
+func (v *validator) checkPaths(configs []*WatcherConfig, actions Actions, field string) {
+	for idx, wc := range configs {
+		wcField := fmt.Sprintf("%s[%d]", field, idx)
+		if _, err := os.Stat(wc.Name); err != nil {
+			v.reportf(fieldPath(wcField, "name"), "%v", err)
+		}
+		v.checkPatterns(fieldPath(wcField, "exclude"), wc.Excludes)
+		v.checkPatterns(fieldPath(wcField, "include"), wc.Includes)
+		if err := actions.validate(wc.action()); err != nil {
+			v.reportf(fieldPath(wcField, "action"), "%v", err)
+		}
+		for ruleIdx, rule := range wc.Rules {
+			ruleField := fmt.Sprintf("%s[%d]", fieldPath(wcField, "on"), ruleIdx)
+			if err := validatePattern(rule.Pattern); err != nil {
+				v.reportf(fieldPath(ruleField, "pattern"), "invalid pattern %s: %v", rule.Pattern, err)
+			}
+			if err := actions.validate(rule.Action); err != nil {
+				v.reportf(fieldPath(ruleField, "action"), "%v", err)
+			}
+		}
+	}
+}
+
+// validateTarget checks the file or the directory of the target exists, or go can find the package of the import path.
+func validateTarget(target string) error {
+	if strings.HasSuffix(target, ".go") || strings.HasPrefix(target, ".") || filepath.IsAbs(target) {
+		if _, err := os.Stat(target); err != nil {
+			return err
+		}
+		return nil
+	}
+	out, err := exec.Command("go", "list", target).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("cannot find package %s: %s", target, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func validateDockerContainer(name string) error {
+	out, err := exec.Command("docker", "inspect", "-f", "{{.State.Running}}", name).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("cannot inspect docker container %s: %s", name, msg)
+		}
+		return fmt.Errorf("cannot inspect docker container %s: %v", name, err)
+	}
+	if strings.TrimSpace(string(out)) != "true" {
+		return fmt.Errorf("docker container %s is not running", name)
+	}
+	return nil
+}
+
+// Validate reports the unknown fields, invalid patterns, missing paths and targets, and unreachable docker containers.
+// the positions in the file are available only for the config loaded by LoadConfig.
+func (c *Config) Validate() error {
+	v, err := newValidator(c.filename, c.source)
+	if err != nil {
+		return err
+	}
+	if v.root != nil {
+		v.checkUnknownFields(v.root, reflect.TypeOf(c).Elem(), "")
+	}
+
+	build := c.Build
+	if build == nil {
+		build = defaultOption().build
+	}
+	if len(c.Services) == 0 {
+		v.checkBuild(build, "build")
+	}
+	paths := c.Paths
+	if len(paths) == 0 {
+		paths = defaultOption().configs
+	}
+	if len(c.Services) == 0 {
+		v.checkPaths(paths, c.Actions, "path")
+	}
+	for idx, sc := range c.Services {
+		field := fmt.Sprintf("services[%d]", idx)
+		if sc.Build == nil {
+			v.reportf(field, "build is required for service %s", sc.Name)
+			continue
+		}
+		v.checkBuild(sc.Build, fieldPath(field, "build"))
+		v.checkHost(sc.Host, fieldPath(field, "host"))
+		v.checkPaths(sc.Paths, c.Actions, fieldPath(field, "path"))
+	}
+	if c.ExcludePath != nil {
+		v.checkPatterns("exclude", *c.ExcludePath)
+	}
+	for name, action := range c.Actions {
+		v.checkCommands(action.Commands, fieldPath(fieldPath("actions", name), "run"))
+	}
+	if err := c.Debounce.Validate(); err != nil {
+		v.reportf("debounce", "%v", err)
+	}
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}